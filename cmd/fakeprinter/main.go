@@ -0,0 +1,24 @@
+// Command fakeprinter listens like a network receipt printer and prints the jobs it receives
+// to stdout as text, for trying out printing without the hardware.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/KhetwalDevesh/restaurant-management/printer"
+	"log"
+)
+
+func main() {
+	address := flag.String("addr", ":"+printer.DefaultPort, "address to listen on")
+	flag.Parse()
+
+	fake, err := printer.ListenFake(*address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("fake printer listening on %s", fake.Addr())
+	for job := range fake.Received() {
+		fmt.Println(printer.Text(job))
+	}
+}
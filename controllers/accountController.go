@@ -0,0 +1,334 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/mailer"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrUserTokenInvalid is returned for emailed tokens that are malformed, expired, used or superseded.
+	ErrUserTokenInvalid = errors.New("this link is invalid or has expired, please ask for a new one")
+	// ErrEmailNotVerified is returned when logging in before verifying the email address, when that is required.
+	ErrEmailNotVerified = errors.New("please verify your email address before logging in")
+	// ErrEmailThrottled is returned when an account is sent emails of the same kind too soon after one another.
+	ErrEmailThrottled = errors.New("an email was sent to this address a moment ago")
+	// ErrNoTokenURL is returned when an emailed link has no page on this server to default to and none is set.
+	ErrNoTokenURL = errors.New("no URL is set for the emailed link")
+)
+
+const (
+	// userTokenEmailInterval is how long an account waits before being sent another email of the same kind.
+	userTokenEmailInterval = time.Minute
+	// userTokenEmailTimeout bounds looking up the account and sending it an email in the background.
+	userTokenEmailTimeout = time.Minute
+)
+
+// EmailRequest names the account to email.
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetRequest sets a new password with the token from a password reset email.
+type PasswordResetRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// VerifyEmail marks the email address of an account as verified. It is what the link in the
+// verification email opens, with the token as a query parameter.
+func VerifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			user, err := redeemUserToken(tx, c.Query("token"), models.VerifyEmail)
+			if err != nil {
+				return err
+			}
+			if user.EmailVerifiedAt != nil {
+				return nil
+			}
+			return tx.Model(&user).Update("email_verified_at", time.Now()).Error
+		})
+		if err != nil {
+			c.JSON(userTokenErrorStatus(err), gin.H{"error": userTokenError(err)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "your email address is verified"})
+	}
+}
+
+// ResendVerificationEmail emails a new verification link to an unverified account. It answers the same,
+// and just as fast, whether or not the account exists, so it can't be used to find out who has one.
+func ResendVerificationEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindEmailRequest(c)
+		if !ok {
+			return
+		}
+		go emailUserToken(request.Email, models.VerifyEmail)
+		c.JSON(http.StatusOK, gin.H{"message": "if the account exists and is not verified yet, a verification email is on its way"})
+	}
+}
+
+// ForgotPassword emails a password reset link. It answers the same, and just as fast, whether or not the account exists.
+func ForgotPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request, ok := bindEmailRequest(c)
+		if !ok {
+			return
+		}
+		go emailUserToken(request.Email, models.ResetPassword)
+		c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a password reset email is on its way"})
+	}
+}
+
+// ResetPassword sets a new password with the token from a password reset email and logs the user out
+// of every session. Having received the email also verifies the address.
+func ResetPassword() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request PasswordResetRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Password reset invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		password := HashPassword(request.Password)
+		var user models.User
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			user, err = redeemUserToken(tx, request.Token, models.ResetPassword)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			update := map[string]interface{}{
+				"password":   password,
+				"updated_at": now,
+			}
+			if user.EmailVerifiedAt == nil {
+				update["email_verified_at"] = now
+			}
+			if err := tx.Model(&user).Updates(update).Error; err != nil {
+				return err
+			}
+			// the password only changes together with logging out whoever had the old one
+			_, err = revokeUserTokensIn(tx, user.ID, user.ID)
+			return err
+		})
+		if err != nil {
+			c.JSON(userTokenErrorStatus(err), gin.H{"error": userTokenError(err)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "your password was changed, please log in again"})
+	}
+}
+
+func bindEmailRequest(c *gin.Context) (EmailRequest, bool) {
+	var request EmailRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return request, false
+	}
+	if err := validate.Struct(request); err != nil {
+		msg := fmt.Sprintf("Email invalidated : %v", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return request, false
+	}
+	return request, true
+}
+
+// emailUserToken sends the account with the email address a token for the purpose, if there is such
+// an account and, for verification, it isn't verified yet. It runs in the background of requests that
+// answer the same either way, so looking the account up and emailing it don't show in their timing.
+func emailUserToken(email string, purpose models.UserTokenPurpose) {
+	ctx, cancel := context.WithTimeout(context.Background(), userTokenEmailTimeout)
+	defer cancel()
+	var user models.User
+	err := config.GetDB().WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && purpose == models.VerifyEmail && user.EmailVerifiedAt != nil) {
+		return
+	}
+	if err == nil {
+		err = sendUserToken(ctx, user, purpose)
+	}
+	if err != nil {
+		log.Printf("%s email to %s was not sent: %v", purpose, email, err)
+	}
+}
+
+// sendUserToken issues a token for the purpose, replacing the unused ones the user already had, and emails
+// it to them as a link. Accounts are sent one email of a kind per userTokenEmailInterval at most.
+func sendUserToken(ctx context.Context, user models.User, purpose models.UserTokenPurpose) error {
+	signedToken, tokenId, err := helper.GenerateUserToken(user.ID, purpose)
+	if err != nil {
+		return err
+	}
+	message := mailer.Message{To: user.Email}
+	switch purpose {
+	case models.VerifyEmail:
+		link, err := userTokenLink("VERIFY_EMAIL_URL", "/verifyEmail", signedToken)
+		if err != nil {
+			return err
+		}
+		message.Subject = "Verify your email address"
+		message.Body = fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening this link:\n\n%s\n\nThe link expires in %v.\n",
+			user.Name, link, helper.UserTokenLifetime(purpose))
+	case models.ResetPassword:
+		link, err := userTokenLink("PASSWORD_RESET_URL", "", signedToken)
+		if err != nil {
+			return err
+		}
+		message.Subject = "Reset your password"
+		message.Body = fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open this link to choose a new one:\n\n%s\n\nThe link expires in %v. If you did not ask for it, you can ignore this email.\n",
+			user.Name, link, helper.UserTokenLifetime(purpose))
+	}
+	// the link and the mailer are checked before the token is stored, so a mailer that isn't set up
+	// doesn't hold the next email back
+	m, err := mailer.Default()
+	if err != nil {
+		return err
+	}
+
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		// the user is locked so two requests at once can't both get past the throttle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.ID).First(&models.User{}).Error; err != nil {
+			return err
+		}
+		var recent int64
+		err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, purpose, time.Now().Add(-userTokenEmailInterval)).
+			Count(&recent).Error
+		if err != nil {
+			return err
+		}
+		if recent > 0 {
+			return ErrEmailThrottled
+		}
+		err = tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).Delete(&models.UserToken{}).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&models.UserToken{
+			TokenId:   tokenId,
+			UserId:    user.ID,
+			Purpose:   purpose,
+			ExpiresAt: now.Add(helper.UserTokenLifetime(purpose)),
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return m.Send(ctx, message)
+}
+
+// redeemUserToken checks an emailed token and marks it used, returning the user it was issued to.
+func redeemUserToken(tx *gorm.DB, signedToken string, purpose models.UserTokenPurpose) (models.User, error) {
+	var user models.User
+	claims, err := helper.ValidateUserToken(signedToken, purpose)
+	if err != nil {
+		return user, ErrUserTokenInvalid
+	}
+	var userToken models.UserToken
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_id = ?", claims.Id).First(&userToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return user, err
+	}
+	if err := checkUserToken(userToken, claims, purpose, time.Now()); err != nil {
+		return user, err
+	}
+	if err := tx.Model(&userToken).Update("used_at", time.Now()).Error; err != nil {
+		return user, err
+	}
+	if err := tx.Where("id = ?", userToken.UserId).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrUserTokenInvalid
+		}
+		return user, err
+	}
+	return user, nil
+}
+
+// checkUserToken checks the stored token behind claims can still be used for the purpose at now:
+// it was issued to the same user for the purpose, and it was not used yet and has not expired.
+func checkUserToken(userToken models.UserToken, claims *helper.UserTokenClaims, purpose models.UserTokenPurpose, now time.Time) error {
+	if userToken.UsedAt != nil || userToken.Purpose != purpose || userToken.UserId != claims.UserId || now.After(userToken.ExpiresAt) {
+		return ErrUserTokenInvalid
+	}
+	return nil
+}
+
+// userTokenLink builds the link an emailed token is sent as, the URL in env with the token added
+// as a query parameter. It defaults to path on this server, and has to be set when there is no path,
+// like for password resets, which only have a POST route for the page at the URL to send the form to.
+func userTokenLink(env string, path string, token string) (string, error) {
+	base := os.Getenv(env)
+	if base == "" {
+		if path == "" {
+			return "", fmt.Errorf("%w: set %s", ErrNoTokenURL, env)
+		}
+		port := os.Getenv("SERVER_PORT")
+		if port == "" {
+			port = "8000"
+		}
+		base = "http://localhost:" + port + path
+	}
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// emailVerificationRequired reports whether REQUIRE_EMAIL_VERIFICATION keeps unverified accounts from logging in.
+func emailVerificationRequired() bool {
+	required, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION"))
+	return required
+}
+
+// checkEmailVerified keeps the user from logging in with an unverified address when that is required.
+func checkEmailVerified(user models.User) error {
+	if emailVerificationRequired() && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+func userTokenErrorStatus(err error) int {
+	if errors.Is(err, ErrUserTokenInvalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func userTokenError(err error) string {
+	if errors.Is(err, ErrUserTokenInvalid) {
+		return err.Error()
+	}
+	return "error occurred while checking the link"
+}
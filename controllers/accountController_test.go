@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	jwt "github.com/dgrijalva/jwt-go"
+	"testing"
+	"time"
+)
+
+func TestCheckUserToken(t *testing.T) {
+	now := time.Now()
+	used := now.Add(-time.Minute)
+	claims := &helper.UserTokenClaims{UserId: 7, StandardClaims: jwt.StandardClaims{Id: "token"}}
+	valid := models.UserToken{TokenId: "token", UserId: 7, Purpose: models.ResetPassword, ExpiresAt: now.Add(time.Hour)}
+
+	if err := checkUserToken(valid, claims, models.ResetPassword, now); err != nil {
+		t.Errorf("a valid token was rejected: %v", err)
+	}
+	cases := map[string]func(token *models.UserToken) models.UserTokenPurpose{
+		"used": func(token *models.UserToken) models.UserTokenPurpose {
+			token.UsedAt = &used
+			return models.ResetPassword
+		},
+		"expired": func(token *models.UserToken) models.UserTokenPurpose {
+			token.ExpiresAt = now.Add(-time.Second)
+			return models.ResetPassword
+		},
+		"wrong purpose": func(token *models.UserToken) models.UserTokenPurpose {
+			return models.VerifyEmail
+		},
+		"other user": func(token *models.UserToken) models.UserTokenPurpose {
+			token.UserId = 8
+			return models.ResetPassword
+		},
+	}
+	for name, change := range cases {
+		token := valid
+		purpose := change(&token)
+		if err := checkUserToken(token, claims, purpose, now); !errors.Is(err, ErrUserTokenInvalid) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrUserTokenInvalid)
+		}
+	}
+}
+
+func TestCheckEmailVerified(t *testing.T) {
+	verified := time.Now()
+	unverified := models.User{}
+
+	t.Setenv("REQUIRE_EMAIL_VERIFICATION", "true")
+	if err := checkEmailVerified(unverified); !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("an unverified user got %v, want %v", err, ErrEmailNotVerified)
+	}
+	if err := checkEmailVerified(models.User{EmailVerifiedAt: &verified}); err != nil {
+		t.Errorf("a verified user was blocked: %v", err)
+	}
+
+	t.Setenv("REQUIRE_EMAIL_VERIFICATION", "false")
+	if err := checkEmailVerified(unverified); err != nil {
+		t.Errorf("an unverified user was blocked without REQUIRE_EMAIL_VERIFICATION: %v", err)
+	}
+}
+
+func TestUserTokenLink(t *testing.T) {
+	t.Setenv("SERVER_PORT", "8080")
+	t.Setenv("VERIFY_EMAIL_URL", "")
+	t.Setenv("PASSWORD_RESET_URL", "")
+
+	link, err := userTokenLink("VERIFY_EMAIL_URL", "/verifyEmail", "abc")
+	if err != nil || link != "http://localhost:8080/verifyEmail?token=abc" {
+		t.Errorf("verification link defaulted to %q, %v", link, err)
+	}
+	if _, err := userTokenLink("PASSWORD_RESET_URL", "", "abc"); !errors.Is(err, ErrNoTokenURL) {
+		t.Errorf("a reset link without PASSWORD_RESET_URL got %v, want %v", err, ErrNoTokenURL)
+	}
+
+	t.Setenv("PASSWORD_RESET_URL", "https://example.com/reset?lang=en")
+	link, err = userTokenLink("PASSWORD_RESET_URL", "", "abc")
+	if err != nil || link != "https://example.com/reset?lang=en&token=abc" {
+		t.Errorf("reset link was %q, %v", link, err)
+	}
+}
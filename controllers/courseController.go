@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/kitchen"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrNothingToFire is returned when a course has no held items left to send to the kitchen.
+var ErrNothingToFire = errors.New("this course has no held items to fire")
+
+// CourseView sums up one course of an order for the waiter.
+type CourseView struct {
+	Course  uint32
+	Held    int
+	Fired   int
+	Ready   int
+	FiredAt *time.Time
+	Items   []*models.OrderItem
+}
+
+// GetOrderCourses lists the courses of an order with how far each has got.
+func GetOrderCourses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderId, err := strconv.ParseUint(c.Param("order_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+			return
+		}
+		var orderItems []*models.OrderItem
+		err = config.GetDB().Preload("Food").Preload("Modifiers").
+			Where("order_id = ?", orderId).
+			Order("course asc, id asc").
+			Find(&orderItems).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the order courses"})
+			return
+		}
+
+		courses := []*CourseView{}
+		for _, item := range orderItems {
+			if len(courses) == 0 || courses[len(courses)-1].Course != item.Course {
+				courses = append(courses, &CourseView{Course: item.Course})
+			}
+			course := courses[len(courses)-1]
+			course.Items = append(course.Items, item)
+			switch {
+			case item.FiredAt == nil:
+				course.Held++
+			case item.PrepStatus == models.PrepReady:
+				course.Ready++
+				course.Fired++
+			default:
+				course.Fired++
+			}
+			if item.FiredAt != nil && (course.FiredAt == nil || item.FiredAt.After(*course.FiredAt)) {
+				course.FiredAt = item.FiredAt
+			}
+		}
+		c.JSON(http.StatusOK, courses)
+	}
+}
+
+// FireCourse sends the held items of a course to the kitchen.
+func FireCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		course, err := strconv.ParseUint(c.Param("course"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid course"})
+			return
+		}
+		orderId := c.Param("order_id")
+		var order models.Order
+		if err := config.GetDB().Where("id = ?", orderId).First(&order).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if order.Status.IsTerminal() {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + string(order.Status)})
+			return
+		}
+
+		var fired []models.OrderItem
+		err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			// only items still held are fired, so firing twice doesn't send a course to the kitchen again
+			result := tx.Model(&fired).
+				Clauses(clause.Returning{}).
+				Where("order_id = ? AND course = ? AND fired_at IS NULL", order.ID, course).
+				Updates(map[string]interface{}{"fired_at": now, "updated_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrNothingToFire
+			}
+			for _, item := range fired {
+				if err := kitchen.Publish(tx, kitchen.Event{Type: kitchen.ItemFired, OrderItemId: item.ID, OrderId: item.OrderId}); err != nil {
+					return err
+				}
+			}
+			return recordOrderHistory(tx, models.OrderHistory{
+				OrderId: order.ID,
+				Action:  models.OrderHistoryCourseFired,
+				Note:    "course " + strconv.FormatUint(course, 10),
+				UserId:  currentUserId(c),
+			})
+		})
+		if err != nil {
+			if errors.Is(err, ErrNothingToFire) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fire the course"})
+			return
+		}
+
+		orderItemIds := make([]uint32, 0, len(fired))
+		for _, item := range fired {
+			orderItemIds = append(orderItemIds, item.ID)
+		}
+		go printOrderItems(orderItemIds)
+		c.JSON(http.StatusOK, gin.H{"message": "Course fired", "orderItemIds": orderItemIds})
+	}
+}
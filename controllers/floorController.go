@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// ErrSectionNotFound is returned when a table is put in a floor section that does not exist.
+var ErrSectionNotFound = errors.New("floor section not found")
+
+// FloorSectionUpdate is the body accepted when updating a floor section, pointers tell unset fields from zero values.
+type FloorSectionUpdate struct {
+	Name      string `json:"name"`
+	SortOrder *int   `json:"sortOrder"`
+}
+
+// TableStatusRequest sets a table's manual status, free marks a dirty or cleaning table ready again.
+type TableStatusRequest struct {
+	Status models.TableStatus `json:"status" validate:"oneof=free dirty cleaning"`
+}
+
+// TableView is a table along with what is going on at it. SeatedAt is when its earliest open order
+// was placed and OrderTotal what its open orders add up to before discounts and taxes.
+type TableView struct {
+	models.Table
+	Status     models.TableStatus `json:"status"`
+	OrderIds   []uint32           `json:"orderIds,omitempty"`
+	SeatedAt   *time.Time         `json:"seatedAt,omitempty"`
+	OrderTotal float64            `json:"orderTotal"`
+}
+
+// FloorSectionView is a floor section with its tables.
+type FloorSectionView struct {
+	models.FloorSection
+	Tables []TableView `json:"tables"`
+}
+
+func GetFloorSections() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sections []*models.FloorSection
+		if err := config.GetDB().Order("sort_order asc, id asc").Find(&sections).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetFloorSections got error"})
+			return
+		}
+		c.JSON(http.StatusOK, sections)
+	}
+}
+
+func CreateFloorSection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var section models.FloorSection
+		if err := c.BindJSON(&section); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		section.CreatedAt = time.Now()
+		section.UpdatedAt = time.Now()
+
+		if err := validate.Struct(section); err != nil {
+			msg := fmt.Sprintf("Floor section invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Create(&section).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding the floor section"})
+			return
+		}
+		c.JSON(http.StatusOK, section)
+	}
+}
+
+func UpdateFloorSection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update FloorSectionUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sectionId := c.Param("section_id")
+		var section models.FloorSection
+		if err := config.GetDB().Where("id = ?", sectionId).First(&section).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Floor section not found"})
+			return
+		}
+
+		if update.Name != "" {
+			section.Name = update.Name
+		}
+		if update.SortOrder != nil {
+			section.SortOrder = *update.SortOrder
+		}
+		section.UpdatedAt = time.Now()
+
+		if err := config.GetDB().Save(&section).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Floor section update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, section)
+	}
+}
+
+// GetFloor returns every floor section with its tables and what is going on at them,
+// tables that are not in any section are listed as unassigned.
+func GetFloor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sections []models.FloorSection
+		if err := config.GetDB().Order("sort_order asc, id asc").Find(&sections).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the floor"})
+			return
+		}
+		var tables []models.Table
+		if err := config.GetDB().Order("table_number asc, id asc").Find(&tables).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the floor"})
+			return
+		}
+		views, err := tableViewsOf(config.GetDB(), tables)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the floor"})
+			return
+		}
+
+		floor := make([]FloorSectionView, len(sections))
+		sectionIndex := make(map[uint]int, len(sections))
+		for i, section := range sections {
+			floor[i] = FloorSectionView{FloorSection: section, Tables: []TableView{}}
+			sectionIndex[section.ID] = i
+		}
+		unassigned := []TableView{}
+		for _, view := range views {
+			if view.SectionId != nil {
+				if i, ok := sectionIndex[*view.SectionId]; ok {
+					floor[i].Tables = append(floor[i].Tables, view)
+					continue
+				}
+			}
+			unassigned = append(unassigned, view)
+		}
+		c.JSON(http.StatusOK, gin.H{"sections": floor, "unassigned": unassigned, "at": time.Now()})
+	}
+}
+
+// SetTableStatus marks a free table as dirty or being cleaned, or as ready again.
+func SetTableStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request TableStatusRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Table status invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		tableId := c.Param("table_id")
+		var table models.Table
+		if err := config.GetDB().Where("id = ?", tableId).First(&table).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+
+		manualStatus := request.Status
+		if manualStatus == models.TableFree {
+			manualStatus = ""
+		} else {
+			var openCount int64
+			err := config.GetDB().Model(&models.Order{}).
+				Where("table_id = ? AND status NOT IN ?", table.ID, closedOrderStatuses).
+				Count(&openCount).Error
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Table status update failed"})
+				return
+			}
+			if openCount > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%v: table %d has an open order", ErrTableOccupied, table.TableNumber)})
+				return
+			}
+		}
+
+		table.ManualStatus = manualStatus
+		table.UpdatedAt = time.Now()
+		err := config.GetDB().Model(&table).Updates(map[string]interface{}{"manual_status": table.ManualStatus, "updated_at": table.UpdatedAt}).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Table status update failed"})
+			return
+		}
+		views, err := tableViewsOf(config.GetDB(), []models.Table{table})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the table"})
+			return
+		}
+		c.JSON(http.StatusOK, views[0])
+	}
+}
+
+// closedOrderStatuses are the statuses of orders whose guests no longer take up their table.
+var closedOrderStatuses = []models.OrderStatus{models.OrderClosed, models.OrderCancelled, models.OrderVoided, models.OrderMerged}
+
+// tableViewsOf works out the status of each table. Tables with an open order are occupied,
+// or awaiting payment once the order has been billed and an invoice is still unpaid.
+// Other tables are free unless staff marked them dirty or being cleaned.
+func tableViewsOf(tx *gorm.DB, tables []models.Table) ([]TableView, error) {
+	views := make([]TableView, len(tables))
+	if len(tables) == 0 {
+		return views, nil
+	}
+	tableIds := make([]uint32, len(tables))
+	for i, table := range tables {
+		tableIds[i] = uint32(table.ID)
+	}
+
+	var orders []models.Order
+	err := tx.Where("table_id IN ? AND status NOT IN ?", tableIds, closedOrderStatuses).
+		Order("order_date asc").
+		Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	orderIds := make([]uint32, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.ID
+	}
+
+	totals := make(map[uint32]float64)
+	unpaid := make(map[uint32]bool)
+	if len(orderIds) > 0 {
+		var items []*models.OrderItem
+		if err := tx.Preload("Modifiers").Where("order_id IN ?", orderIds).Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			totals[item.OrderId] += lineTotal(item)
+		}
+		var invoices []models.Invoice
+		if err := tx.Where("order_id IN ?", orderIds).Find(&invoices).Error; err != nil {
+			return nil, err
+		}
+		for _, invoice := range invoices {
+			if !invoice.PaymentStatus.Settled() {
+				unpaid[invoice.OrderID] = true
+			}
+		}
+	}
+
+	viewIndex := make(map[uint32]int, len(tables))
+	for i, table := range tables {
+		views[i] = TableView{Table: table, Status: models.TableFree}
+		if table.ManualStatus != "" {
+			views[i].Status = table.ManualStatus
+		}
+		viewIndex[uint32(table.ID)] = i
+	}
+	for _, order := range orders {
+		view := &views[viewIndex[*order.TableId]]
+		if view.SeatedAt == nil {
+			orderDate := order.OrderDate
+			view.SeatedAt = &orderDate
+			view.Status = models.TableOccupied
+		}
+		view.OrderIds = append(view.OrderIds, order.ID)
+		view.OrderTotal = toFixed(view.OrderTotal+totals[order.ID], 2)
+		if unpaid[order.ID] {
+			view.Status = models.TableAwaitingPayment
+		}
+	}
+	return views, nil
+}
+
+// checkFloorSection makes sure the section a table is put in exists.
+func checkFloorSection(sectionId *uint) error {
+	if sectionId == nil {
+		return nil
+	}
+	var count int64
+	if err := config.GetDB().Model(&models.FloorSection{}).Where("id = ?", *sectionId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrSectionNotFound, *sectionId)
+	}
+	return nil
+}
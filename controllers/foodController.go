@@ -23,7 +23,7 @@ func GetFoods() gin.HandlerFunc {
 			page = 1
 		}
 		offset, limit := helpers.GetLimitOffset(20, page)
-		config.GetDB().Model(models.Food{}).Offset(offset).Limit(limit + 1).Find(&foodItems)
+		config.GetDB().Model(models.Food{}).Preload("Variants").Offset(offset).Limit(limit + 1).Find(&foodItems)
 		nextPage := false
 		if len(foodItems) > limit {
 			nextPage = true
@@ -47,7 +47,7 @@ func GetFood() gin.HandlerFunc {
 		_, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		foodId := c.Param("food_id")
 		var food models.Food
-		err := config.GetDB().Model(models.Food{}).Where("food_id = ?", foodId).First(&food).Error
+		err := config.GetDB().Model(models.Food{}).Preload("Variants").Where("id = ?", foodId).First(&food).Error
 		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the food item"})
@@ -59,11 +59,6 @@ func GetFood() gin.HandlerFunc {
 func CreateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		isUserAdmin, _ := c.Get("isAdmin")
-		if isUserAdmin == false {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You need to be an admin to create a food"})
-			return
-		}
 		var menu *models.Menu
 		var food *models.Food
 
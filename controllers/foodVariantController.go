@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+func GetFoodVariants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		foodId := c.Param("food_id")
+		var variants []*models.FoodVariant
+		if err := config.GetDB().Where("food_id = ?", foodId).Order("price asc, id asc").Find(&variants).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the food variants"})
+			return
+		}
+		c.JSON(http.StatusOK, variants)
+	}
+}
+
+func CreateFoodVariant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var variant models.FoodVariant
+		if err := c.BindJSON(&variant); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foodId := c.Param("food_id")
+		var food models.Food
+		if err := config.GetDB().Where("id = ?", foodId).First(&food).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+
+		variant.FoodId = food.ID
+		variant.Price = toFixed(variant.Price, 2)
+		variant.CreatedAt = time.Now()
+		variant.UpdatedAt = time.Now()
+
+		// validate variant data before storing it in db
+		if err := validate.Struct(variant); err != nil {
+			msg := fmt.Sprintf("Food variant invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		taken, err := variantSizeTaken(variant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the food variants"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("this food already has a %s variant", variant.Size)})
+			return
+		}
+
+		if err := config.GetDB().Create(&variant).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the food variant"})
+			return
+		}
+		c.JSON(http.StatusOK, variant)
+	}
+}
+
+func UpdateFoodVariant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var variant models.FoodVariant
+		if err := c.BindJSON(&variant); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foodId := c.Param("food_id")
+		variantId := c.Param("variant_id")
+		var existingVariant models.FoodVariant
+		if err := config.GetDB().Where("id = ? AND food_id = ?", variantId, foodId).First(&existingVariant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food variant not found"})
+			return
+		}
+
+		if variant.Name != "" {
+			existingVariant.Name = variant.Name
+		}
+		if variant.Size != "" {
+			existingVariant.Size = variant.Size
+		}
+		if variant.Price != 0 {
+			existingVariant.Price = toFixed(variant.Price, 2)
+		}
+		existingVariant.UpdatedAt = time.Now()
+
+		// validate variant data before storing it in db
+		if err := validate.Struct(existingVariant); err != nil {
+			msg := fmt.Sprintf("Food variant invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		taken, err := variantSizeTaken(existingVariant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the food variants"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("this food already has a %s variant", existingVariant.Size)})
+			return
+		}
+
+		if err := config.GetDB().Save(&existingVariant).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Food variant update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingVariant)
+	}
+}
+
+// variantSizeTaken reports whether another variant of the same food already uses the variant's size.
+func variantSizeTaken(variant models.FoodVariant) (bool, error) {
+	if variant.Size == "" {
+		return false, nil
+	}
+	var count int64
+	err := config.GetDB().Model(&models.FoodVariant{}).
+		Where("food_id = ? AND size = ? AND id <> ?", variant.FoodId, variant.Size, variant.ID).
+		Count(&count).Error
+	return count > 0, err
+}
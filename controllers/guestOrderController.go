@@ -0,0 +1,498 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxPendingGuestSubmissions is how many orders a table may have waiting for staff before guests have to wait.
+	maxPendingGuestSubmissions = 3
+	// guestSubmissionHistory is how far back guests see what was ordered from their table.
+	guestSubmissionHistory = 12 * time.Hour
+)
+
+var (
+	// ErrSubmissionReviewed is returned when approving or rejecting a guest order that staff already reviewed.
+	ErrSubmissionReviewed = errors.New("the guest order has already been reviewed")
+	// ErrFoodNotOnMenu is returned when a guest orders a food that is not on any menu running right now.
+	ErrFoodNotOnMenu = errors.New("food is not on the menu right now")
+)
+
+// GuestMenuView is a menu as guests browse it, with the prices they would pay right now.
+type GuestMenuView struct {
+	ID       uint32          `json:"id"`
+	Name     string          `json:"name"`
+	Category string          `json:"category"`
+	Foods    []GuestFoodView `json:"foods"`
+}
+
+type GuestFoodView struct {
+	ID             uint32                 `json:"id"`
+	Name           string                 `json:"name"`
+	Image          string                 `json:"image,omitempty"`
+	Price          float64                `json:"price"`
+	Variants       []GuestVariantView     `json:"variants,omitempty"`
+	ModifierGroups []models.ModifierGroup `json:"modifierGroups,omitempty"`
+}
+
+type GuestVariantView struct {
+	ID    uint32          `json:"id"`
+	Name  string          `json:"name"`
+	Size  models.Quantity `json:"size,omitempty"`
+	Price float64         `json:"price"`
+}
+
+// GuestSubmissionReview is the body accepted when rejecting a guest order.
+type GuestSubmissionReview struct {
+	Reason string `json:"reason"`
+}
+
+// GetGuestMenu lists the menus running right now for guests ordering from their table.
+func GetGuestMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		var menus []models.Menu
+		err := config.GetDB().Preload("Foods.Variants").
+			Where("start_date <= ? AND end_date >= ?", now, now).
+			Order("id asc").
+			Find(&menus).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the menu"})
+			return
+		}
+
+		views := make([]GuestMenuView, 0, len(menus))
+		for _, menu := range menus {
+			view := GuestMenuView{ID: menu.ID, Name: menu.Name, Category: menu.Category, Foods: []GuestFoodView{}}
+			for _, food := range menu.Foods {
+				foodView, err := guestFoodViewOf(config.GetDB(), food, now)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while loading the menu"})
+					return
+				}
+				view.Foods = append(view.Foods, foodView)
+			}
+			views = append(views, view)
+		}
+		tableNumber, _ := c.Get("guestTableNumber")
+		c.JSON(http.StatusOK, gin.H{"tableNumber": tableNumber, "menus": views})
+	}
+}
+
+// SubmitGuestOrder takes the items a guest ordered from their table and holds them for staff to approve.
+func SubmitGuestOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var submission models.GuestSubmission
+		if err := c.BindJSON(&submission); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tableId := c.MustGet("guestTableId").(uint)
+		submission.ID = 0
+		submission.TableId = tableId
+		submission.Status = models.GuestSubmissionPending
+		submission.OrderId = nil
+		submission.Reason = ""
+		submission.ReviewedBy = nil
+		submission.ReviewedAt = nil
+		submission.CreatedAt = time.Now()
+		submission.UpdatedAt = time.Now()
+		for i := range submission.Items {
+			submission.Items[i].ID = 0
+			submission.Items[i].SubmissionId = 0
+		}
+
+		if err := validate.Struct(submission); err != nil {
+			msg := fmt.Sprintf("Order invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		var pendingCount int64
+		err := config.GetDB().Model(&models.GuestSubmission{}).
+			Where("table_id = ? AND status = ?", tableId, models.GuestSubmissionPending).
+			Count(&pendingCount).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending the order"})
+			return
+		}
+		if pendingCount >= maxPendingGuestSubmissions {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "your earlier orders are still waiting for staff, please wait a moment"})
+			return
+		}
+
+		// price the items the way staff will when approving them, turning away anything that could not be ordered
+		for i := range submission.Items {
+			if err := priceGuestItem(config.GetDB(), &submission.Items[i]); err != nil {
+				status := orderItemErrorStatus(err)
+				if errors.Is(err, ErrFoodNotOnMenu) {
+					status = http.StatusBadRequest
+				}
+				if status == http.StatusInternalServerError {
+					c.JSON(status, gin.H{"error": "Error sending the order"})
+					return
+				}
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := config.GetDB().Create(&submission).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending the order"})
+			return
+		}
+		c.JSON(http.StatusOK, submission)
+	}
+}
+
+// GetGuestOrders lists what was recently ordered from the guest's table and whether staff approved it.
+func GetGuestOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tableId := c.MustGet("guestTableId").(uint)
+		var submissions []models.GuestSubmission
+		err := config.GetDB().Preload("Items").
+			Where("table_id = ? AND created_at > ?", tableId, time.Now().Add(-guestSubmissionHistory)).
+			Order("created_at desc, id desc").
+			Find(&submissions).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the orders"})
+			return
+		}
+		c.JSON(http.StatusOK, submissions)
+	}
+}
+
+// GetGuestSubmissions lists guest orders for staff, the pending ones unless another status is asked for.
+func GetGuestSubmissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", string(models.GuestSubmissionPending))
+		query := config.GetDB().Preload("Items").Where("status = ?", status)
+		if tableId := c.Query("table_id"); tableId != "" {
+			query = query.Where("table_id = ?", tableId)
+		}
+		var submissions []models.GuestSubmission
+		if err := query.Order("created_at asc, id asc").Find(&submissions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the guest orders"})
+			return
+		}
+		c.JSON(http.StatusOK, submissions)
+	}
+}
+
+// ApproveGuestSubmission turns a guest order into order items on the table's open order,
+// opening an order when the table has none, and sends them to the kitchen.
+func ApproveGuestSubmission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		submissionId := c.Param("submission_id")
+		userId := currentUserId(c)
+		var submission models.GuestSubmission
+		var orderItems []models.OrderItem
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			if submission, err = lockPendingSubmission(tx, submissionId); err != nil {
+				return err
+			}
+
+			order, err := openOrderAt(tx, uint32(submission.TableId), models.Order{})
+			if err != nil {
+				return err
+			}
+			if order.ID == 0 {
+				now := time.Now()
+				tableId := uint32(submission.TableId)
+				order = models.Order{OrderDate: now, Status: models.OrderPlaced, Type: models.DineIn, TableId: &tableId, UserId: userId, CreatedAt: now, UpdatedAt: now}
+				if err := tx.Create(&order).Error; err != nil {
+					return err
+				}
+				err := recordOrderHistory(tx, models.OrderHistory{
+					OrderId:  order.ID,
+					Action:   models.OrderHistoryStatus,
+					ToStatus: order.Status,
+					Note:     fmt.Sprintf("opened for guest order %d", submission.ID),
+					UserId:   userId,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, item := range submission.Items {
+				orderItems = append(orderItems, models.OrderItem{
+					FoodId:        item.FoodId,
+					FoodVariantId: item.FoodVariantId,
+					Quantity:      item.Quantity,
+					Seat:          item.Seat,
+					Notes:         item.Notes,
+					ModifierIds:   item.ModifierIds,
+				})
+			}
+			if err := createOrderItems(tx, order.ID, orderItems, false, userId); err != nil {
+				return err
+			}
+			err = recordOrderHistory(tx, models.OrderHistory{
+				OrderId: order.ID,
+				Action:  models.OrderHistoryGuestItems,
+				Note:    fmt.Sprintf("guest order %d approved", submission.ID),
+				UserId:  userId,
+			})
+			if err != nil {
+				return err
+			}
+			return reviewSubmission(tx, &submission, models.GuestSubmissionApproved, &order.ID, "", userId)
+		})
+		if err != nil {
+			status := guestSubmissionErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": "Failed to review the guest order"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if orderItemIds := firedOrderItemIds(orderItems); len(orderItemIds) > 0 {
+			go printOrderItems(orderItemIds)
+		}
+		c.JSON(http.StatusOK, gin.H{"submission": submission, "orderItems": orderItems})
+	}
+}
+
+func RejectGuestSubmission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var review GuestSubmissionReview
+		if err := c.BindJSON(&review); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		submissionId := c.Param("submission_id")
+		var submission models.GuestSubmission
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			if submission, err = lockPendingSubmission(tx, submissionId); err != nil {
+				return err
+			}
+			return reviewSubmission(tx, &submission, models.GuestSubmissionRejected, nil, review.Reason, currentUserId(c))
+		})
+		if err != nil {
+			status := guestSubmissionErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": "Failed to review the guest order"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, submission)
+	}
+}
+
+// GetTableGuestToken returns the table's QR ordering token and the link its QR code opens.
+func GetTableGuestToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table models.Table
+		if err := config.GetDB().Where("id = ?", c.Param("table_id")).First(&table).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+		token, link, err := guestOrderLink(table)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while signing the guest token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tableId": table.ID, "tableNumber": table.TableNumber, "token": token, "url": link})
+	}
+}
+
+// GetTableQRCode renders the QR code guests scan to order from the table, as a png unless format=svg is asked for.
+func GetTableQRCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		size, err := strconv.Atoi(c.DefaultQuery("size", "512"))
+		if err != nil || size < 64 || size > 4096 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size has to be between 64 and 4096"})
+			return
+		}
+		format := c.DefaultQuery("format", "png")
+		if format != "png" && format != "svg" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format has to be png or svg"})
+			return
+		}
+
+		var table models.Table
+		if err := config.GetDB().Where("id = ?", c.Param("table_id")).First(&table).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+		_, link, err := guestOrderLink(table)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while signing the guest token"})
+			return
+		}
+
+		var image []byte
+		contentType := "image/png"
+		if format == "svg" {
+			image, err = helpers.QRCodeSVG(link, size)
+			contentType = "image/svg+xml"
+		} else {
+			image, err = helpers.QRCodePNG(link, size)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while drawing the QR code"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=table-%d.%s", table.TableNumber, format))
+		c.Data(http.StatusOK, contentType, image)
+	}
+}
+
+// RotateTableGuestToken revokes every QR code printed for the table so far and returns the new token.
+func RotateTableGuestToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var table models.Table
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", c.Param("table_id")).First(&table).Error; err != nil {
+				return err
+			}
+			table.GuestTokenVersion++
+			table.UpdatedAt = time.Now()
+			return tx.Model(&table).Updates(map[string]interface{}{"guest_token_version": table.GuestTokenVersion, "updated_at": table.UpdatedAt}).Error
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while revoking the QR codes"})
+			return
+		}
+		token, link, err := guestOrderLink(table)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while signing the guest token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tableId": table.ID, "tableNumber": table.TableNumber, "token": token, "url": link})
+	}
+}
+
+// guestOrderLink signs the table's guest token and builds the link its QR code opens,
+// GUEST_ORDER_URL with the token added as a query parameter.
+func guestOrderLink(table models.Table) (string, string, error) {
+	token, err := helpers.GenerateGuestToken(table.ID, table.GuestTokenVersion)
+	if err != nil {
+		return "", "", err
+	}
+	base := os.Getenv("GUEST_ORDER_URL")
+	if base == "" {
+		port := os.Getenv("SERVER_PORT")
+		if port == "" {
+			port = "8000"
+		}
+		base = "http://localhost:" + port + "/guest/menu"
+	}
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return token, link.String(), nil
+}
+
+func guestFoodViewOf(tx *gorm.DB, food models.Food, at time.Time) (GuestFoodView, error) {
+	price, err := resolveFoodPrice(tx, food, nil, at)
+	if err != nil {
+		return GuestFoodView{}, err
+	}
+	view := GuestFoodView{ID: food.ID, Name: food.Name, Image: food.Image, Price: price}
+	for i := range food.Variants {
+		variantPrice, err := resolveFoodPrice(tx, food, &food.Variants[i], at)
+		if err != nil {
+			return view, err
+		}
+		view.Variants = append(view.Variants, GuestVariantView{ID: food.Variants[i].ID, Name: food.Variants[i].Name, Size: food.Variants[i].Size, Price: variantPrice})
+	}
+	if err := tx.Preload("Modifiers").Where("food_id = ?", food.ID).Order("id asc").Find(&view.ModifierGroups).Error; err != nil {
+		return view, err
+	}
+	return view, nil
+}
+
+// priceGuestItem checks the item can be ordered from a menu running right now and sets what it costs.
+func priceGuestItem(tx *gorm.DB, item *models.GuestSubmissionItem) error {
+	now := time.Now()
+	var onMenu int64
+	err := tx.Model(&models.Food{}).
+		Joins("JOIN menus m ON m.id = foods.menu_id").
+		Where("foods.id = ? AND m.start_date <= ? AND m.end_date >= ?", item.FoodId, now, now).
+		Count(&onMenu).Error
+	if err != nil {
+		return err
+	}
+	if onMenu == 0 {
+		return fmt.Errorf("%w: %d", ErrFoodNotOnMenu, item.FoodId)
+	}
+
+	orderItem := models.OrderItem{FoodId: item.FoodId, FoodVariantId: item.FoodVariantId, Quantity: item.Quantity, ModifierIds: item.ModifierIds}
+	if err := priceOrderItem(tx, &orderItem, 0, false, 0); err != nil {
+		return err
+	}
+	if err := selectModifiers(tx, &orderItem); err != nil {
+		return err
+	}
+	item.UnitPrice = toFixed(lineTotal(&orderItem)/float64(item.Quantity), 2)
+	return nil
+}
+
+func lockPendingSubmission(tx *gorm.DB, submissionId string) (models.GuestSubmission, error) {
+	var submission models.GuestSubmission
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", submissionId).First(&submission).Error
+	if err != nil {
+		return submission, err
+	}
+	if submission.Status != models.GuestSubmissionPending {
+		return submission, fmt.Errorf("%w: it was %s", ErrSubmissionReviewed, submission.Status)
+	}
+	err = tx.Where("submission_id = ?", submission.ID).Order("id asc").Find(&submission.Items).Error
+	return submission, err
+}
+
+func reviewSubmission(tx *gorm.DB, submission *models.GuestSubmission, status models.GuestSubmissionStatus, orderId *uint32, reason string, userId uint32) error {
+	now := time.Now()
+	submission.Status = status
+	submission.OrderId = orderId
+	submission.Reason = reason
+	submission.ReviewedBy = &userId
+	submission.ReviewedAt = &now
+	submission.UpdatedAt = now
+	return tx.Model(&models.GuestSubmission{}).Where("id = ?", submission.ID).Updates(map[string]interface{}{
+		"status":      submission.Status,
+		"order_id":    submission.OrderId,
+		"reason":      submission.Reason,
+		"reviewed_by": submission.ReviewedBy,
+		"reviewed_at": submission.ReviewedAt,
+		"updated_at":  submission.UpdatedAt,
+	}).Error
+}
+
+func guestSubmissionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSubmissionReviewed):
+		return http.StatusConflict
+	default:
+		return orderItemErrorStatus(err)
+	}
+}
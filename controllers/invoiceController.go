@@ -7,6 +7,8 @@ import (
 	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"math"
 	"net/http"
 	"time"
 )
@@ -20,11 +22,52 @@ type InvoiceViewFormat struct {
 	PaymentStatus  models.PaymentStatus
 	PaymentDue     interface{}
 	TableNumber    interface{}
+	OrderType      models.OrderType
+	CustomerName   string
+	Address        string
+	SplitMode      models.SplitMode
+	SplitIndex     uint32
+	SplitCount     uint32
+	Lines          []InvoiceLineView
+	Subtotal       float64
+	Discounts      []InvoiceDiscountLine
+	DiscountTotal  float64
+	Taxes          []InvoiceTaxLine
+	TaxTotal       float64
+	DeliveryFee    float64
 	TotalAmount    float64
+	AmountPaid     float64
+	TipTotal       float64
+	Balance        float64
+	Payments       []models.Payment
 	PaymentDueDate time.Time
 	OrderDetails   interface{}
 }
 
+// InvoiceLineView is an order item as billed on an invoice. Share is the part of the
+// order item the invoice covers, 1 unless the bill was split.
+type InvoiceLineView struct {
+	OrderItemId uint32
+	Name        string
+	VariantName string
+	Seat        uint32
+	Quantity    uint32
+	UnitPrice   float64
+	Modifiers   []models.OrderItemModifier
+	Notes       string
+	Share       float64
+	Amount      float64
+}
+
+// invoiceLine is what one order item adds to an invoice, as discounts and taxes see it.
+// Quantity is fractional when the invoice only covers a share of the order item.
+type invoiceLine struct {
+	FoodId   uint32
+	Category string
+	Quantity float64
+	Amount   float64
+}
+
 func GetInvoices() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -41,55 +84,212 @@ func GetInvoices() gin.HandlerFunc {
 func GetInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		invoiceId := c.Param("invoice_id")
 		var invoice *models.Invoice
 		err := config.GetDB().Model(models.Invoice{}).Where("id = ?", invoiceId).First(&invoice).Error
-		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
 		}
-		var invoiceView InvoiceViewFormat
-		allOrderItems, er := ItemsByOrder(invoice.OrderID)
-		if er != nil {
+		invoiceView, err := invoiceViewOf(invoice)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
 		}
-		_ = config.GetDB().Model(models.OrderItem{}).Select("SUM(quantity * unit_price) as total_amount").Where("order_id = ?", invoice.OrderID).Scan(&invoiceView.TotalAmount).Error
-		invoiceView.OrderId = invoice.OrderID
-		invoiceView.PaymentDueDate = invoice.PaymentDueDate
-		invoiceView.PaymentMethod = invoice.PaymentMethod
-		invoiceView.Id = invoice.ID
-		invoiceView.PaymentStatus = invoice.PaymentStatus
-		invoiceView.TableNumber = allOrderItems[0].Order.Table.TableNumber
-		invoiceView.OrderDetails = allOrderItems
 		c.JSON(http.StatusOK, invoiceView)
 	}
 }
 
+// invoiceViewOf puts together the invoice with its order items and totals.
+func invoiceViewOf(invoice *models.Invoice) (InvoiceViewFormat, error) {
+	return invoiceViewIn(config.GetDB(), invoice)
+}
+
+// invoiceViewIn computes the invoice view within tx, seeing what the transaction has written so far.
+func invoiceViewIn(tx *gorm.DB, invoice *models.Invoice) (InvoiceViewFormat, error) {
+	var invoiceView InvoiceViewFormat
+	var order models.Order
+	if err := tx.Preload("Table").Where("id = ?", invoice.OrderID).First(&order).Error; err != nil {
+		return invoiceView, err
+	}
+	allOrderItems, err := itemsByOrder(tx, invoice.OrderID)
+	if err != nil {
+		return invoiceView, err
+	}
+	var taxRates []models.InvoiceTaxRate
+	if err := tx.Where("invoice_id = ?", invoice.ID).Order("id asc").Find(&taxRates).Error; err != nil {
+		return invoiceView, err
+	}
+
+	shares, err := invoiceShares(tx, invoice)
+	if err != nil {
+		return invoiceView, err
+	}
+	var splitShares map[uint32][]splitShare
+	if shares != nil {
+		if splitShares, err = orderSplitShares(tx, invoice.OrderID); err != nil {
+			return invoiceView, err
+		}
+	}
+
+	billedItems := make([]*models.OrderItem, 0, len(allOrderItems))
+	lines := make([]invoiceLine, 0, len(allOrderItems))
+	for _, item := range allOrderItems {
+		share := 1.0
+		if shares != nil {
+			share = shares[item.ID]
+		}
+		if share == 0 {
+			continue
+		}
+		amount := toFixed(lineTotal(item), 2)
+		if shares != nil {
+			amount = shareAmount(lineTotal(item), splitShares[item.ID], invoice.ID)
+		}
+		invoiceView.Subtotal += amount
+		billedItems = append(billedItems, item)
+		lines = append(lines, invoiceLine{FoodId: item.FoodId, Category: item.Food.Menu.Category, Quantity: float64(item.Quantity) * share, Amount: amount})
+		invoiceView.Lines = append(invoiceView.Lines, InvoiceLineView{
+			OrderItemId: item.ID,
+			Name:        item.Food.Name,
+			VariantName: item.VariantName,
+			Seat:        item.Seat,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Modifiers:   item.Modifiers,
+			Notes:       item.Notes,
+			Share:       share,
+			Amount:      amount,
+		})
+	}
+	invoiceView.Subtotal = toFixed(invoiceView.Subtotal, 2)
+
+	// discounts come off before taxes are charged
+	var discounts []models.InvoiceDiscount
+	if err := tx.Where("invoice_id = ?", invoice.ID).Order("id asc").Find(&discounts).Error; err != nil {
+		return invoiceView, err
+	}
+	lines, invoiceView.Discounts = computeDiscounts(lines, invoiceView.Subtotal, discounts)
+	for _, discount := range invoiceView.Discounts {
+		invoiceView.DiscountTotal += discount.Amount
+	}
+	invoiceView.DiscountTotal = toFixed(invoiceView.DiscountTotal, 2)
+
+	// inclusive taxes are already part of the subtotal, only the exclusive ones are added on top
+	var exclusiveTaxes float64
+	invoiceView.Taxes, exclusiveTaxes = computeTaxes(lines, taxRates)
+	for _, tax := range invoiceView.Taxes {
+		invoiceView.TaxTotal += tax.Amount
+	}
+	invoiceView.TaxTotal = toFixed(invoiceView.TaxTotal, 2)
+	// the delivery fee is neither discounted nor taxed, and is billed once on the order's first invoice
+	if order.Type == models.Delivery && invoice.SplitIndex <= 1 {
+		invoiceView.DeliveryFee = order.DeliveryFee
+	}
+	invoiceView.TotalAmount = toFixed(invoiceView.Subtotal-invoiceView.DiscountTotal+exclusiveTaxes+invoiceView.DeliveryFee, 2)
+
+	if err := tx.Where("invoice_id = ?", invoice.ID).Order("id asc").Find(&invoiceView.Payments).Error; err != nil {
+		return invoiceView, err
+	}
+	invoiceView.AmountPaid, invoiceView.TipTotal = ledgerTotals(invoiceView.Payments)
+	invoiceView.Balance = toFixed(invoiceView.TotalAmount-invoiceView.AmountPaid, 2)
+	invoiceView.OrderId = invoice.OrderID
+	invoiceView.PaymentDueDate = invoice.PaymentDueDate
+	invoiceView.PaymentMethod = invoice.PaymentMethod
+	invoiceView.Id = invoice.ID
+	invoiceView.PaymentStatus = invoice.PaymentStatus
+	invoiceView.SplitMode = invoice.SplitMode
+	invoiceView.SplitIndex = invoice.SplitIndex
+	invoiceView.SplitCount = invoice.SplitCount
+	if order.Table != nil {
+		invoiceView.TableNumber = order.Table.TableNumber
+	}
+	invoiceView.OrderType = order.Type
+	invoiceView.CustomerName = order.CustomerName
+	invoiceView.Address = order.DeliveryAddress
+	invoiceView.OrderDetails = billedItems
+	return invoiceView, nil
+}
+
+// invoiceShares returns the share of each order item a split invoice covers,
+// or nil for an invoice covering the whole order.
+func invoiceShares(tx *gorm.DB, invoice *models.Invoice) (map[uint32]float64, error) {
+	if invoice.SplitMode == "" {
+		return nil, nil
+	}
+	var invoiceItems []models.InvoiceItem
+	if err := tx.Where("invoice_id = ?", invoice.ID).Find(&invoiceItems).Error; err != nil {
+		return nil, err
+	}
+	shares := make(map[uint32]float64, len(invoiceItems))
+	for _, invoiceItem := range invoiceItems {
+		shares[invoiceItem.OrderItemId] += invoiceItem.Share
+	}
+	return shares, nil
+}
+
+// splitShare is the share of an order item one of the order's split invoices covers.
+type splitShare struct {
+	InvoiceId   uint32
+	OrderItemId uint32
+	Share       float64
+}
+
+// orderSplitShares returns the shares of each order item across every invoice of the order, in split order.
+func orderSplitShares(tx *gorm.DB, orderId uint32) (map[uint32][]splitShare, error) {
+	var rows []splitShare
+	err := tx.Model(&models.InvoiceItem{}).
+		Select("invoice_items.invoice_id, invoice_items.order_item_id, invoice_items.share").
+		Joins("JOIN invoices ON invoices.id = invoice_items.invoice_id").
+		Where("invoices.order_id = ?", orderId).
+		Order("invoices.split_index asc, invoice_items.id asc").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[uint32][]splitShare)
+	for _, row := range rows {
+		byItem[row.OrderItemId] = append(byItem[row.OrderItemId], row)
+	}
+	return byItem, nil
+}
+
+// shareAmount is what an invoice bills for its shares of an order item. Every share is rounded to the cent
+// but the last one, which takes what is left, so the shares of an item always add up to its total.
+func shareAmount(total float64, shares []splitShare, invoiceId uint32) float64 {
+	var covered float64
+	for _, share := range shares {
+		covered += share.Share
+	}
+	var billed, amount float64
+	for i, share := range shares {
+		portion := toFixed(total*share.Share, 2)
+		if i == len(shares)-1 && math.Abs(covered-1) < 1e-9 {
+			portion = toFixed(total-billed, 2)
+		}
+		billed += portion
+		if share.InvoiceId == invoiceId {
+			amount += portion
+		}
+	}
+	return toFixed(amount, 2)
+}
+
 func CreateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isUserAdmin, _ := c.Get("isAdmin")
-		if isUserAdmin == false {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You need to be an admin to create an invoice"})
-			return
-		}
 		var invoice *models.Invoice
 		if err := c.BindJSON(&invoice); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		var order *models.Order
+		invoice.SplitMode = ""
+		invoice.SplitIndex = 0
+		invoice.SplitCount = 0
 
-		if err := config.GetDB().Where("id = ?", invoice.OrderID).First(&order).Error; err != nil {
-			msg := fmt.Sprintf("Order was not found")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
-		}
-
-		status := "pending"
-		if invoice.PaymentStatus == "" {
-			invoice.PaymentStatus = models.PaymentStatus(status)
-		}
+		// the payment status follows the payments taken against the invoice
+		invoice.PaymentStatus = models.Pending
 
 		invoice.PaymentDueDate = time.Now().AddDate(0, 0, 1)
 		invoice.CreatedAt = time.Now()
@@ -101,8 +301,22 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		// Save the invoice to the database
-		if err := config.GetDB().Create(&invoice).Error; err != nil {
+		// Save the invoice to the database along with the tax rates in effect right now. The order
+		// is locked first so it is billed once, splitting it is done through the split endpoint.
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if _, err := lockOpenOrder(tx, invoice.OrderID); err != nil {
+				return err
+			}
+			if err := tx.Create(&invoice).Error; err != nil {
+				return err
+			}
+			return snapshotTaxRates(tx, invoice.ID)
+		})
+		if err != nil {
+			if status := billingErrorStatus(err); status != http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
 			msg := fmt.Sprintf("Invoice item was not created")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
@@ -114,6 +328,7 @@ func CreateInvoice() gin.HandlerFunc {
 func UpdateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var invoice *models.Invoice
 		invoiceID := c.Param("invoice_id")
 
@@ -128,15 +343,22 @@ func UpdateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
 			return
 		}
-		defer cancel()
 
 		// Update the invoice fields
 		if invoice.PaymentMethod != "" {
 			existingInvoice.PaymentMethod = invoice.PaymentMethod
 		}
 
-		if invoice.PaymentStatus != "" {
-			existingInvoice.PaymentStatus = invoice.PaymentStatus
+		// marking an invoice as paid takes a payment for its balance, any other status has to come from payments and refunds
+		settle := invoice.PaymentStatus == models.Paid && !existingInvoice.PaymentStatus.Settled()
+		if invoice.PaymentStatus != "" && invoice.PaymentStatus != existingInvoice.PaymentStatus && !settle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the payment status follows the invoice's payments, record a payment or a refund instead"})
+			return
+		}
+		// cards are charged with a card token, which only the payments endpoint takes
+		if settle && existingInvoice.PaymentMethod == models.Card {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "card invoices are paid through POST /invoices/:invoice_id/payments with a cardToken"})
+			return
 		}
 
 		existingInvoice.UpdatedAt = time.Now()
@@ -147,12 +369,43 @@ func UpdateInvoice() gin.HandlerFunc {
 			return
 		}
 
+		var total float64
+		if settle {
+			invoiceView, err := invoiceViewOf(existingInvoice)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+				return
+			}
+			total = invoiceView.TotalAmount
+		}
+
 		// Save the updated invoice
-		if err := config.GetDB().Save(&existingInvoice).Error; err != nil {
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit("payment_status").Save(&existingInvoice).Error; err != nil {
+				return err
+			}
+			if !settle {
+				return nil
+			}
+			payment := models.Payment{Method: existingInvoice.PaymentMethod, UserId: currentUserId(c)}
+			if key := c.GetHeader("Idempotency-Key"); key != "" {
+				payment.IdempotencyKey = &key
+			}
+			_, err := recordPayment(tx, existingInvoice.ID, total, payment)
+			return err
+		})
+		if err != nil {
+			if status := paymentErrorStatus(err); status != http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
 			msg := fmt.Sprintf("Invoice update failed")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if settle {
+			existingInvoice.PaymentStatus = models.Paid
+		}
 
 		c.JSON(http.StatusOK, existingInvoice)
 	}
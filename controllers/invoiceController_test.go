@@ -0,0 +1,39 @@
+package controller
+
+import "testing"
+
+func TestShareAmount(t *testing.T) {
+	thirds := []splitShare{{InvoiceId: 1, Share: 1.0 / 3}, {InvoiceId: 2, Share: 1.0 / 3}, {InvoiceId: 3, Share: 1.0 / 3}}
+	halves := []splitShare{{InvoiceId: 1, Share: 0.5}, {InvoiceId: 2, Share: 0.5}}
+
+	cases := []struct {
+		name      string
+		total     float64
+		shares    []splitShare
+		invoiceId uint32
+		want      float64
+	}{
+		{name: "first third", total: 10, shares: thirds, invoiceId: 1, want: 3.33},
+		{name: "second third", total: 10, shares: thirds, invoiceId: 2, want: 3.33},
+		{name: "last third takes the remainder cent", total: 10, shares: thirds, invoiceId: 3, want: 3.34},
+		{name: "half a cent rounds up for the first half", total: 0.01, shares: halves, invoiceId: 1, want: 0.01},
+		{name: "the last half gets what is left of a cent", total: 0.01, shares: halves, invoiceId: 2, want: 0},
+		{name: "a share of part of the item takes no remainder", total: 9.99, shares: []splitShare{{InvoiceId: 1, Share: 0.5}}, invoiceId: 1, want: 5},
+		{name: "an invoice with no share", total: 10, shares: thirds, invoiceId: 4, want: 0},
+	}
+	for _, tc := range cases {
+		if got := shareAmount(tc.total, tc.shares, tc.invoiceId); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, total := range []float64{10, 0.01, 0.02, 99.99, 100} {
+		var sum float64
+		for _, invoiceId := range []uint32{1, 2, 3} {
+			sum += shareAmount(total, thirds, invoiceId)
+		}
+		if toFixed(sum, 2) != total {
+			t.Errorf("thirds of %v add up to %v", total, sum)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/kitchen"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// kitchenHeartbeat keeps idle kitchen streams from being closed by proxies.
+const kitchenHeartbeat = 30 * time.Second
+
+// KitchenOrderView groups the outstanding items of one order for a kitchen display.
+// TableId and TableNumber are only set for dine-in orders, CustomerName and PickupAt for takeaway and delivery.
+type KitchenOrderView struct {
+	OrderId      uint32
+	Type         models.OrderType
+	TableId      *uint32
+	TableNumber  uint
+	CustomerName string
+	PickupAt     *time.Time
+	OrderDate    time.Time
+	Items        []*models.OrderItem
+}
+
+// KitchenItemEvent is what kitchen displays receive for every change to an order item.
+// Item is nil when the order item no longer exists.
+type KitchenItemEvent struct {
+	Event kitchen.Event
+	Item  *models.OrderItem
+}
+
+// GetKitchenQueue lists what the kitchen still has to prepare, only what is routed to a station when given a station_id.
+func GetKitchenQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stationId, err := kitchenStationParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		queue, err := kitchenQueue(stationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the kitchen queue"})
+			return
+		}
+		c.JSON(http.StatusOK, queue)
+	}
+}
+
+// StreamKitchen sends the current kitchen queue as a "snapshot" event followed by
+// every change to order items as Server-Sent Events. Streams for a station_id only get the
+// station's items, and an "item_removed" event when one of them is sent to another station.
+func StreamKitchen() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stationId, err := kitchenStationParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		events, unsubscribe := kitchen.Subscribe()
+		defer unsubscribe()
+
+		queue, err := kitchenQueue(stationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the kitchen queue"})
+			return
+		}
+		// the items this display was sent, so it can be told when they leave the station
+		shown := make(map[uint32]bool)
+		showQueue := func(queue []*KitchenOrderView) {
+			shown = make(map[uint32]bool)
+			for _, view := range queue {
+				for _, item := range view.Items {
+					shown[item.ID] = true
+				}
+			}
+		}
+		showQueue(queue)
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("snapshot", queue)
+
+		heartbeat := time.NewTicker(kitchenHeartbeat)
+		defer heartbeat.Stop()
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					// we fell behind, the display reconnects and starts from a fresh snapshot
+					return false
+				}
+				if event.Type == kitchen.Resync {
+					queue, err := kitchenQueue(stationId)
+					if err != nil {
+						return false
+					}
+					showQueue(queue)
+					c.SSEvent("snapshot", queue)
+					return true
+				}
+				item, err := kitchenItem(event.OrderItemId)
+				if err != nil {
+					return false
+				}
+				if item != nil && item.FiredAt == nil {
+					// held courses stay off the displays until they are fired
+					return true
+				}
+				if stationId != nil && (item == nil || item.StationId == nil || *item.StationId != *stationId) {
+					if shown[event.OrderItemId] {
+						delete(shown, event.OrderItemId)
+						c.SSEvent(string(kitchen.ItemRemoved), KitchenItemEvent{Event: event, Item: item})
+					}
+					return true
+				}
+				shown[event.OrderItemId] = true
+				c.SSEvent(string(event.Type), KitchenItemEvent{Event: event, Item: item})
+				return true
+			case <-heartbeat.C:
+				c.SSEvent("ping", time.Now())
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// BumpOrderItem marks an order item as ready, taking it off the kitchen displays.
+func BumpOrderItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		updateKitchenItem(c, kitchen.ItemBumped, []models.PrepStatus{models.PrepQueued, models.PrepPreparing},
+			map[string]interface{}{"prep_status": models.PrepReady, "bumped_at": now, "updated_at": now})
+	}
+}
+
+// RecallOrderItem puts a bumped order item back on the kitchen displays.
+func RecallOrderItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		updateKitchenItem(c, kitchen.ItemRecalled, []models.PrepStatus{models.PrepReady},
+			map[string]interface{}{"prep_status": models.PrepPreparing, "bumped_at": nil, "updated_at": time.Now()})
+	}
+}
+
+func updateKitchenItem(c *gin.Context, eventType kitchen.EventType, from []models.PrepStatus, changes map[string]interface{}) {
+	orderItemId := c.Param("order_item_id")
+	var orderItem models.OrderItem
+	if err := config.GetDB().Where("id = ?", orderItemId).First(&orderItem).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the order item"})
+		return
+	}
+
+	var updated bool
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrderItem{}).
+			Where("id = ? AND prep_status IN ?", orderItem.ID, from).
+			Updates(changes)
+		if result.Error != nil {
+			return result.Error
+		}
+		updated = result.RowsAffected > 0
+		if !updated {
+			return nil
+		}
+		return kitchen.Publish(tx, kitchen.Event{Type: eventType, OrderItemId: orderItem.ID, OrderId: orderItem.OrderId})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order item"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "order item is " + string(orderItem.PrepStatus)})
+		return
+	}
+
+	item, err := kitchenItem(orderItem.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the order item"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// kitchenItems returns the order items matching the query with everything a kitchen display shows preloaded.
+func kitchenItems(query *gorm.DB) ([]*models.OrderItem, error) {
+	var orderItems []*models.OrderItem
+	err := query.Preload("Food").Preload("Modifiers").Preload("Order.Table").Find(&orderItems).Error
+	return orderItems, err
+}
+
+func kitchenItem(id uint32) (*models.OrderItem, error) {
+	orderItems, err := kitchenItems(config.GetDB().Where("order_items.id = ?", id))
+	if err != nil || len(orderItems) == 0 {
+		return nil, err
+	}
+	return orderItems[0], nil
+}
+
+// kitchenQueue lists the fired items the kitchen, or one of its stations, still has to prepare,
+// grouped by order in the order they came in.
+func kitchenQueue(stationId *uint32) ([]*KitchenOrderView, error) {
+	query := config.GetDB().
+		Joins("JOIN orders o ON o.id = order_items.order_id").
+		Where("order_items.fired_at IS NOT NULL AND order_items.prep_status <> ?", models.PrepReady).
+		Where("o.status NOT IN ?", closedOrderStatuses).
+		Order("o.order_date asc, order_items.id asc")
+	if stationId != nil {
+		query = query.Where("order_items.station_id = ?", *stationId)
+	}
+	orderItems, err := kitchenItems(query)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := []*KitchenOrderView{}
+	byOrder := make(map[uint32]*KitchenOrderView)
+	for _, item := range orderItems {
+		view, ok := byOrder[item.OrderId]
+		if !ok {
+			view = &KitchenOrderView{
+				OrderId:      item.OrderId,
+				Type:         item.Order.Type,
+				TableId:      item.Order.TableId,
+				CustomerName: item.Order.CustomerName,
+				PickupAt:     item.Order.PickupAt,
+				OrderDate:    item.Order.OrderDate,
+			}
+			if item.Order.Table != nil {
+				view.TableNumber = item.Order.Table.TableNumber
+			}
+			byOrder[item.OrderId] = view
+			queue = append(queue, view)
+		}
+		view.Items = append(view.Items, item)
+	}
+	return queue, nil
+}
+
+// kitchenStationParam reads the optional station_id query parameter.
+func kitchenStationParam(c *gin.Context) (*uint32, error) {
+	param := c.Query("station_id")
+	if param == "" {
+		return nil, nil
+	}
+	stationId, err := strconv.ParseUint(param, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid station_id %q", param)
+	}
+	id := uint32(stationId)
+	return &id, nil
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KitchenStationUpdate is the body accepted when updating a kitchen station, pointers tell unset fields from zero values.
+// A PrinterId of 0 takes the station's printer away.
+type KitchenStationUpdate struct {
+	Name      string  `json:"name"`
+	PrinterId *uint32 `json:"printerId"`
+	Active    *bool   `json:"active"`
+}
+
+func GetKitchenStations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var stations []*models.KitchenStation
+		if err := config.GetDB().Preload("Routes").Order("id asc").Find(&stations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetKitchenStations got error"})
+			return
+		}
+		c.JSON(http.StatusOK, stations)
+	}
+}
+
+func CreateKitchenStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var station models.KitchenStation
+		if err := c.BindJSON(&station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		station.Routes = nil
+		station.Active = true
+		station.CreatedAt = time.Now()
+		station.UpdatedAt = time.Now()
+
+		if err := validateKitchenStation(station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := config.GetDB().Create(&station).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the kitchen station"})
+			return
+		}
+		c.JSON(http.StatusOK, station)
+	}
+}
+
+func UpdateKitchenStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update KitchenStationUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		stationId := c.Param("station_id")
+		var station models.KitchenStation
+		if err := config.GetDB().Where("id = ?", stationId).First(&station).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen station not found"})
+			return
+		}
+
+		if update.Name != "" {
+			station.Name = update.Name
+		}
+		if update.PrinterId != nil {
+			station.PrinterId = update.PrinterId
+			if *update.PrinterId == 0 {
+				station.PrinterId = nil
+			}
+		}
+		if update.Active != nil {
+			station.Active = *update.Active
+		}
+		station.UpdatedAt = time.Now()
+
+		if err := validateKitchenStation(station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := config.GetDB().Omit("Routes").Save(&station).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Kitchen station update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, station)
+	}
+}
+
+// CreateStationRoute routes a food, or every food on menus of a category, to the station.
+func CreateStationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var route models.StationRoute
+		if err := c.BindJSON(&route); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		stationId := c.Param("station_id")
+		var station models.KitchenStation
+		if err := config.GetDB().Where("id = ?", stationId).First(&station).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen station not found"})
+			return
+		}
+		route.StationId = station.ID
+		route.CreatedAt = time.Now()
+		if route.Category != nil {
+			category := strings.TrimSpace(*route.Category)
+			route.Category = &category
+			if category == "" {
+				route.Category = nil
+			}
+		}
+
+		if err := validate.Struct(route); err != nil {
+			msg := fmt.Sprintf("Station route invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if route.FoodId != nil {
+			var food models.Food
+			if err := config.GetDB().Where("id = ?", *route.FoodId).First(&food).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+				return
+			}
+		}
+
+		// a food or category is only ever routed to one station
+		var taken int64
+		query := config.GetDB().Model(&models.StationRoute{})
+		if route.FoodId != nil {
+			query = query.Where("food_id = ?", *route.FoodId)
+		} else {
+			query = query.Where("category = ?", *route.Category)
+		}
+		if err := query.Count(&taken).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the station route"})
+			return
+		}
+		if taken > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "this is already routed to a kitchen station"})
+			return
+		}
+
+		if err := config.GetDB().Create(&route).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the station route"})
+			return
+		}
+		c.JSON(http.StatusOK, route)
+	}
+}
+
+func DeleteStationRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		routeId := c.Param("route_id")
+		result := config.GetDB().Where("id = ?", routeId).Delete(&models.StationRoute{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Station route was not removed"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Station route not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Station route removed successfully"})
+	}
+}
+
+func validateKitchenStation(station models.KitchenStation) error {
+	if err := validate.Struct(station); err != nil {
+		return fmt.Errorf("Kitchen station invalidated : %v", err.Error())
+	}
+	if station.PrinterId != nil {
+		var stationPrinter models.Printer
+		if err := config.GetDB().Where("id = ?", *station.PrinterId).First(&stationPrinter).Error; err != nil {
+			return fmt.Errorf("Kitchen station invalidated : printer %d was not found", *station.PrinterId)
+		}
+		if stationPrinter.Kind != models.KitchenPrinter {
+			return fmt.Errorf("Kitchen station invalidated : %s is not a kitchen printer", stationPrinter.Name)
+		}
+	}
+	return nil
+}
+
+// routeOrderItem sends the order item to the station its food is routed to, directly or through
+// the category of its menu. Items of foods that aren't routed anywhere, or only to inactive
+// stations, are left without a station and show on every display.
+func routeOrderItem(tx *gorm.DB, item *models.OrderItem) error {
+	var food models.Food
+	if err := tx.Preload("Menu").Where("id = ?", item.FoodId).First(&food).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrFoodNotFound
+		}
+		return err
+	}
+
+	var routes []models.StationRoute
+	err := tx.Joins("JOIN kitchen_stations s ON s.id = station_routes.station_id AND s.active").
+		Where("station_routes.food_id = ? OR station_routes.category = ?", food.ID, food.Menu.Category).
+		Find(&routes).Error
+	if err != nil {
+		return err
+	}
+	item.StationId = nil
+	for _, route := range routes {
+		stationId := route.StationId
+		item.StationId = &stationId
+		if route.FoodId != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -28,7 +28,7 @@ func GetMenu() gin.HandlerFunc {
 		_, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		menuId := c.Param("menu_id")
 		var menu models.Menu
-		err := config.GetDB().Model(models.Menu{}).Where("id = ?", menuId).First(&menu).Error
+		err := config.GetDB().Model(models.Menu{}).Preload("Foods.Variants").Where("id = ?", menuId).First(&menu).Error
 		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the menu item"})
@@ -40,11 +40,6 @@ func GetMenu() gin.HandlerFunc {
 func CreateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		isUserAdmin, _ := c.Get("isAdmin")
-		if isUserAdmin == false {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You need to be an admin to create a menu"})
-			return
-		}
 		var menu *models.Menu
 		if err := c.BindJSON(&menu); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
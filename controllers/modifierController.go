@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrModifierNotFound is returned when an order item picks a modifier its food does not offer.
+	ErrModifierNotFound = errors.New("modifier was not found for this food")
+	// ErrModifierChoice is returned when the modifiers picked from a group break its min/max rules.
+	ErrModifierChoice = errors.New("invalid modifier choice")
+)
+
+// ModifierGroupUpdate is the body accepted when updating a modifier group, pointers tell unset fields from zero values.
+type ModifierGroupUpdate struct {
+	Name       string  `json:"name"`
+	Required   *bool   `json:"required"`
+	MinChoices *uint32 `json:"minChoices"`
+	MaxChoices *uint32 `json:"maxChoices"`
+}
+
+func GetModifierGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		foodId := c.Param("food_id")
+		var groups []*models.ModifierGroup
+		err := config.GetDB().Preload("Modifiers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("modifiers.id asc")
+		}).Where("food_id = ?", foodId).Order("id asc").Find(&groups).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the modifier groups"})
+			return
+		}
+		c.JSON(http.StatusOK, groups)
+	}
+}
+
+func CreateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var group models.ModifierGroup
+		if err := c.BindJSON(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		foodId := c.Param("food_id")
+		var food models.Food
+		if err := config.GetDB().Where("id = ?", foodId).First(&food).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+
+		group.FoodId = food.ID
+		group.CreatedAt = time.Now()
+		group.UpdatedAt = time.Now()
+		// modifiers may be sent along with the group
+		for i := range group.Modifiers {
+			group.Modifiers[i].PriceDelta = toFixed(group.Modifiers[i].PriceDelta, 2)
+			group.Modifiers[i].CreatedAt = time.Now()
+			group.Modifiers[i].UpdatedAt = time.Now()
+		}
+
+		if err := validateModifierGroup(group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := config.GetDB().Create(&group).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the modifier group"})
+			return
+		}
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+func UpdateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var group ModifierGroupUpdate
+		if err := c.BindJSON(&group); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		groupId := c.Param("modifier_group_id")
+		var existingGroup models.ModifierGroup
+		if err := config.GetDB().Where("id = ?", groupId).First(&existingGroup).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+			return
+		}
+
+		if group.Name != "" {
+			existingGroup.Name = group.Name
+		}
+		if group.Required != nil {
+			existingGroup.Required = *group.Required
+		}
+		if group.MinChoices != nil {
+			existingGroup.MinChoices = *group.MinChoices
+		}
+		if group.MaxChoices != nil {
+			existingGroup.MaxChoices = *group.MaxChoices
+		}
+		existingGroup.UpdatedAt = time.Now()
+
+		if err := validateModifierGroup(existingGroup); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := config.GetDB().Save(&existingGroup).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Modifier group update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingGroup)
+	}
+}
+
+func CreateModifier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var modifier models.Modifier
+		if err := c.BindJSON(&modifier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		groupId := c.Param("modifier_group_id")
+		var group models.ModifierGroup
+		if err := config.GetDB().Where("id = ?", groupId).First(&group).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier group not found"})
+			return
+		}
+
+		modifier.ModifierGroupId = group.ID
+		modifier.PriceDelta = toFixed(modifier.PriceDelta, 2)
+		modifier.CreatedAt = time.Now()
+		modifier.UpdatedAt = time.Now()
+
+		// validate modifier data before storing it in db
+		if err := validate.Struct(modifier); err != nil {
+			msg := fmt.Sprintf("Modifier invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Create(&modifier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the modifier"})
+			return
+		}
+		c.JSON(http.StatusOK, modifier)
+	}
+}
+
+func UpdateModifier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var modifier struct {
+			Name       string   `json:"name"`
+			PriceDelta *float64 `json:"priceDelta"`
+		}
+		if err := c.BindJSON(&modifier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		modifierId := c.Param("modifier_id")
+		var existingModifier models.Modifier
+		if err := config.GetDB().Where("id = ?", modifierId).First(&existingModifier).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Modifier not found"})
+			return
+		}
+
+		if modifier.Name != "" {
+			existingModifier.Name = modifier.Name
+		}
+		if modifier.PriceDelta != nil {
+			existingModifier.PriceDelta = toFixed(*modifier.PriceDelta, 2)
+		}
+		existingModifier.UpdatedAt = time.Now()
+
+		if err := config.GetDB().Save(&existingModifier).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Modifier update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingModifier)
+	}
+}
+
+func validateModifierGroup(group models.ModifierGroup) error {
+	if err := validate.Struct(group); err != nil {
+		return fmt.Errorf("Modifier group invalidated : %v", err.Error())
+	}
+	if group.MaxChoices != 0 && group.MaxChoices < group.MinimumChoices() {
+		return fmt.Errorf("Modifier group invalidated : maxChoices can not be lower than minChoices")
+	}
+	return nil
+}
+
+// selectModifiers checks the modifiers picked for the item against the modifier groups of its food
+// and snapshots them onto the item.
+func selectModifiers(tx *gorm.DB, item *models.OrderItem) error {
+	var groups []models.ModifierGroup
+	if err := tx.Preload("Modifiers").Where("food_id = ?", item.FoodId).Order("id asc").Find(&groups).Error; err != nil {
+		return err
+	}
+
+	picked := make(map[uint32]bool, len(item.ModifierIds))
+	for _, id := range item.ModifierIds {
+		picked[id] = true
+	}
+
+	item.Modifiers = nil
+	for _, group := range groups {
+		var chosen uint32
+		for _, modifier := range group.Modifiers {
+			if !picked[modifier.ID] {
+				continue
+			}
+			delete(picked, modifier.ID)
+			chosen++
+			item.Modifiers = append(item.Modifiers, models.OrderItemModifier{
+				ModifierId:      modifier.ID,
+				ModifierGroupId: group.ID,
+				Name:            modifier.Name,
+				PriceDelta:      modifier.PriceDelta,
+				CreatedAt:       time.Now(),
+			})
+		}
+		if chosen < group.MinimumChoices() {
+			return fmt.Errorf("%w: %s needs at least %d choice(s)", ErrModifierChoice, group.Name, group.MinimumChoices())
+		}
+		if group.MaxChoices != 0 && chosen > group.MaxChoices {
+			return fmt.Errorf("%w: %s allows at most %d choice(s)", ErrModifierChoice, group.Name, group.MaxChoices)
+		}
+	}
+
+	// whatever is left was not offered by any group of this food
+	for _, id := range item.ModifierIds {
+		if picked[id] {
+			return fmt.Errorf("%w: %d", ErrModifierNotFound, id)
+		}
+	}
+	return nil
+}
+
+// lineTotal returns what the order item costs, including the price of its modifiers.
+func lineTotal(item *models.OrderItem) float64 {
+	unitPrice := item.UnitPrice
+	for _, modifier := range item.Modifiers {
+		unitPrice += modifier.PriceDelta
+	}
+	return toFixed(unitPrice*float64(item.Quantity), 2)
+}
@@ -16,10 +16,21 @@ func GetOrders() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		var orderItems []*models.Order
-		err := config.GetDB().Model(models.Order{}).Find(&orderItems)
+		db := config.GetDB().Model(models.Order{})
+		if status := c.Query("status"); status != "" {
+			db = db.Where("status = ?", status)
+		}
+		if orderType := c.Query("type"); orderType != "" {
+			db = db.Where("type = ?", orderType)
+		}
+		if driverId := c.Query("driver_id"); driverId != "" {
+			db = db.Where("driver_id = ?", driverId)
+		}
+		err := db.Find(&orderItems).Error
 		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetOrders got error"})
+			return
 		}
 		c.JSON(http.StatusOK, orderItems)
 	}
@@ -42,50 +53,30 @@ func GetOrder() gin.HandlerFunc {
 func CreateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var table models.Table
+		defer cancel()
 		var order models.Order
-		// orderDate is not send, current time and date is assigned
-		order.OrderDate = time.Now()
-		// assign the userId to the order to be created
-		userId, userIdExists := c.Get("uid")
-		if userIdExists {
-			order.UserId = userId.(uint32)
-		}
-
 		if err := c.BindJSON(&order); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// check if the table exists from where the order is made
-		if order.TableId != 0 {
-			err := config.GetDB().Model(models.Table{}).Where("id = ?", order.TableId).First(&table).Error
-			defer cancel()
-			if err != nil {
-				msg := fmt.Sprintf("table was not found")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				return
-			}
-		}
-
-		order.CreatedAt = time.Now()
-		order.UpdatedAt = time.Now()
+		// assign the userId to the order to be created
+		order.UserId = currentUserId(c)
 
 		// validate order data before storing it in db
-		if err := validate.Struct(order); err != nil {
-			msg := fmt.Sprintf("Order data invalidated : %v", err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if err := prepareNewOrder(config.GetDB(), &order); err != nil {
+			c.JSON(newOrderErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
-		err := config.GetDB().Model(&models.Order{}).Create(&order).Error
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			return createOrder(tx, &order)
+		})
 		if err != nil {
 			msg := fmt.Sprintf("Error creating the order")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, "Order created successfully!")
+		c.JSON(http.StatusOK, order)
 	}
 }
 
@@ -100,52 +91,354 @@ func UpdateOrder() gin.HandlerFunc {
 
 		orderID := c.Param("order_id")
 
-		// Check if the menu exists
-		var existingOrder *models.Order
-		if err := config.GetDB().Where("id = ?", orderID).First(&existingOrder).Error; err != nil {
+		// Save changes to the database, moving the order to its new table and status if they were sent
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			// the order is locked before anything is read off it, so a driver being assigned or the order
+			// being merged meanwhile is not written over
+			existingOrder, err := lockOpenOrder(tx, orderID)
+			billed := errors.Is(err, ErrOrderBilled)
+			if err != nil && !billed && !errors.Is(err, ErrOrderNotOpen) {
+				return err
+			}
+
+			if order.TableId != nil {
+				if existingOrder.Type != models.DineIn {
+					return ErrOrderHasNoTable
+				}
+				// like TransferOrder, only open orders that haven't been billed move tables
+				if err != nil {
+					return err
+				}
+				if err := checkTable(tx, *order.TableId); err != nil {
+					return err
+				}
+				if err := transferOrder(tx, &existingOrder, *order.TableId, currentUserId(c)); err != nil {
+					return err
+				}
+			}
+
+			changes, err := orderDetailChanges(existingOrder, *order)
+			if err != nil {
+				return err
+			}
+			if len(changes) > 0 {
+				if existingOrder.Status.IsTerminal() {
+					return fmt.Errorf("%w: order %d is %s", ErrOrderNotOpen, existingOrder.ID, existingOrder.Status)
+				}
+				// the invoices were worked out with the fee the order had, it can't change under them
+				if _, feeChanged := changes["delivery_fee"]; feeChanged && billed {
+					return fmt.Errorf("%w: order %d, its delivery fee can't change", ErrOrderBilled, existingOrder.ID)
+				}
+				changes["updated_at"] = time.Now()
+				if err := tx.Model(&models.Order{}).Where("id = ?", existingOrder.ID).Updates(changes).Error; err != nil {
+					return err
+				}
+			}
+
+			if order.Status != "" && order.Status != existingOrder.Status {
+				if err := transitionOrder(tx, &existingOrder, order.Status, currentUserId(c), ""); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, ErrIllegalOrderTransition) || errors.Is(err, ErrOrderNotSettled) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, ErrInvalidOrder) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if status := orderMoveErrorStatus(err); status != http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			msg := "Order update failed"
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order updated successfully"})
+	}
+}
+
+// orderDetailChanges returns the columns of the takeaway and delivery details sent that differ from the order's.
+// The type of an order stays what it was created as, so details that don't apply to it are left out.
+func orderDetailChanges(existing models.Order, sent models.Order) (map[string]interface{}, error) {
+	changes := map[string]interface{}{}
+	if existing.Type == models.DineIn {
+		return changes, nil
+	}
+	updated := existing
+	if sent.CustomerName != "" {
+		updated.CustomerName = sent.CustomerName
+	}
+	if sent.CustomerPhone != "" {
+		updated.CustomerPhone = sent.CustomerPhone
+	}
+	if sent.PickupAt != nil {
+		updated.PickupAt = sent.PickupAt
+	}
+	if sent.DeliveryAddress != "" {
+		updated.DeliveryAddress = sent.DeliveryAddress
+	}
+	if sent.DeliveryFee != 0 {
+		updated.DeliveryFee = sent.DeliveryFee
+	}
+	updated.TrimToType()
+	if err := validate.Struct(updated); err != nil {
+		return nil, fmt.Errorf("%w : %v", ErrInvalidOrder, err.Error())
+	}
+
+	if updated.CustomerName != existing.CustomerName {
+		changes["customer_name"] = updated.CustomerName
+	}
+	if updated.CustomerPhone != existing.CustomerPhone {
+		changes["customer_phone"] = updated.CustomerPhone
+	}
+	if updated.PickupAt != nil && (existing.PickupAt == nil || !updated.PickupAt.Equal(*existing.PickupAt)) {
+		changes["pickup_at"] = updated.PickupAt
+	}
+	if updated.DeliveryAddress != existing.DeliveryAddress {
+		changes["delivery_address"] = updated.DeliveryAddress
+	}
+	if updated.DeliveryFee != existing.DeliveryFee {
+		changes["delivery_fee"] = updated.DeliveryFee
+	}
+	return changes, nil
+}
+
+// ErrInvalidOrder is returned when a new order is missing what its type needs.
+var ErrInvalidOrder = errors.New("Order data invalidated")
+
+// prepareNewOrder readies an order sent by a client to be stored. It starts at the beginning of its lifecycle
+// whatever the client sent, only keeps what applies to its type and has to carry everything its type needs.
+func prepareNewOrder(tx *gorm.DB, order *models.Order) error {
+	// orderDate is not send, current time and date is assigned
+	order.ID = 0
+	order.OrderDate = time.Now()
+	order.Status = models.OrderPlaced
+	order.MergedIntoId = nil
+	order.DriverId = nil
+	order.TrimToType()
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+
+	if err := validate.Struct(order); err != nil {
+		return fmt.Errorf("%w : %v", ErrInvalidOrder, err.Error())
+	}
+	if order.PickupAt != nil && order.PickupAt.Before(time.Now().Add(-time.Minute)) {
+		return fmt.Errorf("%w : the pickup time has already passed", ErrInvalidOrder)
+	}
+	// check if the table exists from where the order is made
+	if order.TableId != nil {
+		return checkTable(tx, *order.TableId)
+	}
+	return nil
+}
+
+// createOrder stores a new order and records it being placed.
+func createOrder(tx *gorm.DB, order *models.Order) error {
+	if err := tx.Create(order).Error; err != nil {
+		return err
+	}
+	return recordOrderHistory(tx, models.OrderHistory{
+		OrderId:  order.ID,
+		Action:   models.OrderHistoryStatus,
+		ToStatus: order.Status,
+		UserId:   order.UserId,
+	})
+}
+
+func newOrderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidOrder):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTableNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrIllegalOrderTransition is returned when an order is asked to move to a status its lifecycle does not allow.
+var ErrIllegalOrderTransition = errors.New("illegal order status transition")
+
+type OrderTransitionRequest struct {
+	Status models.OrderStatus `json:"status" validate:"required"`
+	Note   string             `json:"note"`
+}
+
+func TransitionOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request OrderTransitionRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Transition invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		orderID := c.Param("order_id")
+		var order models.Order
+		if err := config.GetDB().Where("id = ?", orderID).First(&order).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 			return
 		}
 
-		var tableForOrderToBeUpdated *models.Table
-		if order.TableId != 0 {
-			if err := config.GetDB().Model(&models.Table{}).Where("table_id = ?", order.TableId).First(&tableForOrderToBeUpdated).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
-					return
-				}
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "GetTable got error"})
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			return transitionOrder(tx, &order, request.Status, currentUserId(c), request.Note)
+		})
+		if err != nil {
+			if errors.Is(err, ErrIllegalOrderTransition) || errors.Is(err, ErrOrderNotSettled) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 				return
 			}
-			existingOrder.TableId = order.TableId
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order transition failed"})
+			return
 		}
+		c.JSON(http.StatusOK, order)
+	}
+}
 
-		existingOrder.UpdatedAt = time.Now()
+// DriverAssignment names the driver taking a delivery order out, 0 taking the order off its driver.
+type DriverAssignment struct {
+	DriverId uint32 `json:"driverId"`
+}
 
-		// validate order data before storing it in db
-		if err := validate.Struct(existingOrder); err != nil {
-			msg := fmt.Sprintf("Order data invalidated : %v", err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+// AssignDriver gives a delivery order to a driver.
+func AssignDriver() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var assignment DriverAssignment
+		if err := c.BindJSON(&assignment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Save changes to the database
-		if err := config.GetDB().Save(&existingOrder).Error; err != nil {
-			msg := "Order update failed"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		orderID := c.Param("order_id")
+		var order models.Order
+		if err := config.GetDB().Where("id = ?", orderID).First(&order).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		}
+		if order.Type != models.Delivery {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only delivery orders have a driver"})
+			return
+		}
+		if order.Status.IsTerminal() {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%v: order %d is %s", ErrOrderNotOpen, order.ID, order.Status)})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Order updated successfully"})
+		var driverId *uint32
+		note := "driver unassigned"
+		if assignment.DriverId != 0 {
+			var driver models.User
+			if err := config.GetDB().Where("id = ?", assignment.DriverId).First(&driver).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Driver not found"})
+				return
+			}
+			driverId = &driver.ID
+			note = fmt.Sprintf("driver %d (%s) assigned", driver.ID, driver.Name)
+		}
+
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			order.DriverId = driverId
+			order.UpdatedAt = time.Now()
+			err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+				Updates(map[string]interface{}{"driver_id": order.DriverId, "updated_at": order.UpdatedAt}).Error
+			if err != nil {
+				return err
+			}
+			return recordOrderHistory(tx, models.OrderHistory{
+				OrderId: order.ID,
+				Action:  models.OrderHistoryDriverAssigned,
+				Note:    note,
+				UserId:  currentUserId(c),
+			})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Driver assignment failed"})
+			return
+		}
+		c.JSON(http.StatusOK, order)
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) uint32 {
-	// Use GORM to create the order record
-	db := config.GetDB()
-	order.CreatedAt = time.Now()
-	order.UpdatedAt = time.Now()
-	db.Create(&order)
-	// Convert the order ID to hex format
-	return order.ID
+func GetOrderHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID := c.Param("order_id")
+		var history []*models.OrderHistory
+		err := config.GetDB().Where("order_id = ?", orderID).Order("created_at asc, id asc").Find(&history).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the order history"})
+			return
+		}
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+// transitionOrder moves the order to next and records who made the move.
+// The update only applies if the order is still in the status it was read with,
+// so two concurrent transitions cannot both succeed.
+func transitionOrder(tx *gorm.DB, order *models.Order, next models.OrderStatus, userId uint32, note string) error {
+	if !order.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalOrderTransition, order.Status, next)
+	}
+	if next == models.OrderClosed {
+		settled, err := orderSettled(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if !settled {
+			return fmt.Errorf("%w: order %d", ErrOrderNotSettled, order.ID)
+		}
+	}
+	now := time.Now()
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.ID, order.Status).
+		Updates(map[string]interface{}{"status": next, "updated_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: order %d is no longer %s", ErrIllegalOrderTransition, order.ID, order.Status)
+	}
+	previous := order.Status
+	order.Status = next
+	order.UpdatedAt = now
+	return recordOrderHistory(tx, models.OrderHistory{
+		OrderId:    order.ID,
+		Action:     models.OrderHistoryStatus,
+		FromStatus: previous,
+		ToStatus:   next,
+		Note:       note,
+		UserId:     userId,
+	})
+}
+
+func recordOrderHistory(tx *gorm.DB, entry models.OrderHistory) error {
+	entry.CreatedAt = time.Now()
+	return tx.Create(&entry).Error
+}
+
+// currentUserId returns the id of the authenticated user, or 0 when there is none.
+func currentUserId(c *gin.Context) uint32 {
+	userId, userIdExists := c.Get("uid")
+	if !userIdExists {
+		return 0
+	}
+	return userId.(uint32)
+}
+
+// hasPermission reports whether the role of the authenticated user has the permission.
+func hasPermission(c *gin.Context, permission models.Permission) bool {
+	role, _ := c.Get("role")
+	userRole, ok := role.(models.Role)
+	return ok && userRole.Can(permission)
 }
@@ -2,17 +2,24 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/kitchen"
 	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
 	"strconv"
 	"time"
 )
 
+// OrderItemPack carries items for the order their OrderId names, or for a new order when it is 0.
+// A new dine-in order only needs TableId, Order carries the details of a new takeaway or delivery order.
 type OrderItemPack struct {
 	TableId    uint32
+	Order      *models.Order
 	OrderItems []models.OrderItem
 }
 
@@ -49,14 +56,16 @@ func GetOrderItemsByOrder() gin.HandlerFunc {
 }
 
 func ItemsByOrder(id uint32) ([]*models.OrderItem, error) {
-	var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	return itemsByOrder(config.GetDB(), id)
+}
+
+func itemsByOrder(tx *gorm.DB, id uint32) ([]*models.OrderItem, error) {
 	var orderItems []*models.OrderItem
-	err := config.GetDB().Preload("Order.Table").Preload("Food.Menu").Preload("Order.User").
+	err := tx.Preload("Order.Table").Preload("Food.Menu").Preload("FoodVariant").Preload("Modifiers").Preload("Order.User").
 		Joins("JOIN orders o ON o.id = order_items.order_id").
 		Where("o.id = ?", id).
 		Order("order_items.id asc").
 		Find(&orderItems).Error
-	defer cancel()
 	if err != nil {
 		return nil, err
 	}
@@ -78,79 +87,303 @@ func GetOrderItem() gin.HandlerFunc {
 	}
 }
 
+var (
+	// ErrOrderItemFired is returned when moving an order item to another course after it was sent to the kitchen.
+	ErrOrderItemFired = errors.New("order item was already fired to the kitchen")
+	// ErrInvalidOrderItem is returned when a changed order item doesn't validate.
+	ErrInvalidOrderItem = errors.New("Order item invalidated")
+	// ErrKitchenStationNotFound is returned when an order item is sent to a station that does not exist.
+	ErrKitchenStationNotFound = errors.New("Kitchen station not found")
+)
+
+// UpdateOrderItem changes an order item under a lock on its order. What it costs, its quantity, food, variant,
+// price and modifiers, can only change while the order is open and not billed. The kitchen can still move
+// it along, change its seat, notes, course and station once the order is billed.
 func UpdateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		orderItemId := c.Param("order_item_id")
 		var updatedOrderItem models.OrderItem
 		if err := c.BindJSON(&updatedOrderItem); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		canOverridePrice := hasPermission(c, models.PermissionOverridePrices)
+		userId := currentUserId(c)
 
-		// Fetch the existing orderItem from the database
 		var existingOrderItem models.OrderItem
-		err := config.GetDB().Where("id = ?", orderItemId).First(&existingOrderItem).Error
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var orderId uint32
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", orderItemId).Pluck("order_id", &orderId).Error; err != nil {
+				return err
+			}
+			billed := updatedOrderItem.Quantity != 0 || updatedOrderItem.FoodId != 0 || updatedOrderItem.FoodVariantId != nil ||
+				updatedOrderItem.PriceOverrideReason != "" || updatedOrderItem.ModifierIds != nil
+			if _, err := lockOpenOrder(tx, orderId); err != nil && (billed || !errors.Is(err, ErrOrderBilled)) {
+				return err
+			}
+			// read under the order's lock so nothing changes it between here and the save
+			if err := tx.Where("id = ?", orderItemId).First(&existingOrderItem).Error; err != nil {
+				return err
+			}
+			return updateOrderItem(tx, &existingOrderItem, updatedOrderItem, canOverridePrice, userId)
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the existing order item"})
+			status := orderItemErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				status = orderMoveErrorStatus(err)
+			}
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": "Failed to update the order item"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, existingOrderItem)
+	}
+}
 
-		// Update fields if they are provided in the request
-		if updatedOrderItem.Quantity != 0 {
-			existingOrderItem.Quantity = updatedOrderItem.Quantity
+// updateOrderItem applies the update to an order item whose order the caller locked, saves it and lets the kitchen know.
+func updateOrderItem(tx *gorm.DB, existingOrderItem *models.OrderItem, updatedOrderItem models.OrderItem, canOverridePrice bool, userId uint32) error {
+	// Update fields if they are provided in the request
+	if updatedOrderItem.Quantity != 0 {
+		existingOrderItem.Quantity = updatedOrderItem.Quantity
+	}
+
+	originalFoodId := existingOrderItem.FoodId
+	// the price is only re-resolved when the food changes or a manager overrides it,
+	// otherwise the price snapshotted when the item was ordered stands
+	reprice := false
+	if updatedOrderItem.FoodId != 0 && updatedOrderItem.FoodId != existingOrderItem.FoodId {
+		existingOrderItem.FoodId = updatedOrderItem.FoodId
+		existingOrderItem.FoodVariantId = updatedOrderItem.FoodVariantId
+		existingOrderItem.PriceOverrideReason = ""
+		reprice = true
+	} else if updatedOrderItem.FoodVariantId != nil {
+		existingOrderItem.FoodVariantId = updatedOrderItem.FoodVariantId
+		existingOrderItem.PriceOverrideReason = ""
+		reprice = true
+	}
+
+	if updatedOrderItem.PriceOverrideReason != "" {
+		existingOrderItem.PriceOverrideReason = updatedOrderItem.PriceOverrideReason
+		reprice = true
+	}
+
+	if reprice {
+		if err := priceOrderItem(tx, existingOrderItem, updatedOrderItem.UnitPrice, canOverridePrice, userId); err != nil {
+			return err
 		}
+	}
 
-		if updatedOrderItem.UnitPrice != 0 {
-			existingOrderItem.UnitPrice = updatedOrderItem.UnitPrice
+	if updatedOrderItem.Seat != 0 {
+		existingOrderItem.Seat = updatedOrderItem.Seat
+	}
+	if updatedOrderItem.Notes != "" {
+		existingOrderItem.Notes = updatedOrderItem.Notes
+	}
+
+	// modifiers are picked again when sent, or when the food changed since the old ones belong to the old food
+	reselectModifiers := updatedOrderItem.ModifierIds != nil || existingOrderItem.FoodId != originalFoodId
+	if reselectModifiers {
+		existingOrderItem.ModifierIds = updatedOrderItem.ModifierIds
+		if err := selectModifiers(tx, existingOrderItem); err != nil {
+			return err
 		}
+	}
 
-		if updatedOrderItem.FoodId != 0 {
-			existingOrderItem.FoodId = updatedOrderItem.FoodId
+	// an item can only move to another course while its course is held
+	if updatedOrderItem.Course != 0 && updatedOrderItem.Course != existingOrderItem.Course {
+		if existingOrderItem.FiredAt != nil {
+			return ErrOrderItemFired
 		}
-		existingOrderItem.UpdatedAt = time.Now()
+		existingOrderItem.Course = updatedOrderItem.Course
+	}
 
-		// Save the updated order item back to the database
-		err = config.GetDB().Save(&existingOrderItem).Error
-		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the order item"})
-			return
+	// items follow their food to its station unless they are sent somewhere explicitly, 0 taking them off any station
+	if updatedOrderItem.StationId != nil {
+		existingOrderItem.StationId = updatedOrderItem.StationId
+		if *updatedOrderItem.StationId == 0 {
+			existingOrderItem.StationId = nil
+		} else if err := tx.Where("id = ?", *updatedOrderItem.StationId).First(&models.KitchenStation{}).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrKitchenStationNotFound
+			}
+			return err
+		}
+	} else if existingOrderItem.FoodId != originalFoodId {
+		if err := routeOrderItem(tx, existingOrderItem); err != nil {
+			return err
 		}
-		c.JSON(http.StatusOK, existingOrderItem)
 	}
+
+	if updatedOrderItem.PrepStatus != "" {
+		existingOrderItem.PrepStatus = updatedOrderItem.PrepStatus
+		if existingOrderItem.PrepStatus == models.PrepReady {
+			now := time.Now()
+			existingOrderItem.BumpedAt = &now
+		} else {
+			existingOrderItem.BumpedAt = nil
+		}
+	}
+	existingOrderItem.UpdatedAt = time.Now()
+
+	// validate order item data before storing it in db
+	if err := validate.Struct(existingOrderItem); err != nil {
+		return fmt.Errorf("%w : %v", ErrInvalidOrderItem, err)
+	}
+
+	// Save the updated order item back to the database and let the kitchen know about it
+	if err := tx.Omit(clause.Associations).Save(existingOrderItem).Error; err != nil {
+		return err
+	}
+	if reselectModifiers {
+		if err := replaceOrderItemModifiers(tx, existingOrderItem); err != nil {
+			return err
+		}
+	}
+	return kitchen.Publish(tx, kitchen.Event{Type: kitchen.ItemUpdated, OrderItemId: existingOrderItem.ID, OrderId: existingOrderItem.OrderId})
 }
 
 func CreateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var orderItemPack *OrderItemPack
-		var order models.Order
 		if err := c.BindJSON(&orderItemPack); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		order.OrderDate = time.Now()
-		order.TableId = orderItemPack.TableId
-		// assign the userId to the order to be created
-		userId, userIdExists := c.Get("uid")
-		if userIdExists {
-			order.UserId = userId.(uint32)
+		if len(orderItemPack.OrderItems) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no order items were sent"})
+			return
 		}
 		// if Order exists already we just assign the orderItems to it, otherwise we create a new Order
 		orderId := orderItemPack.OrderItems[0].OrderId
+		var order models.Order
 		if orderId == 0 {
-			orderId = OrderItemOrderCreator(order)
+			if orderItemPack.Order != nil {
+				order = *orderItemPack.Order
+			}
+			if order.TableId == nil && orderItemPack.TableId != 0 {
+				order.TableId = &orderItemPack.TableId
+			}
+			// assign the userId to the order to be created
+			order.UserId = currentUserId(c)
+			if err := prepareNewOrder(config.GetDB(), &order); err != nil {
+				c.JSON(newOrderErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
 		}
-		for i := range orderItemPack.OrderItems {
-			orderItemPack.OrderItems[i].OrderId = orderId
-			orderItemPack.OrderItems[i].CreatedAt = time.Now()
-			orderItemPack.OrderItems[i].UpdatedAt = time.Now()
-			var num = toFixed(orderItemPack.OrderItems[i].UnitPrice, 2)
-			orderItemPack.OrderItems[i].UnitPrice = num
+		canOverridePrice := hasPermission(c, models.PermissionOverridePrices)
+		// price the items and create them, then let the kitchen know about them
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if orderId == 0 {
+				if err := createOrder(tx, &order); err != nil {
+					return err
+				}
+				orderId = order.ID
+			}
+			return createOrderItems(tx, orderId, orderItemPack.OrderItems, canOverridePrice, currentUserId(c))
+		})
+		if err != nil {
+			status := orderItemErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": "Failed to create the order items"})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		// held courses are printed when they are fired
+		if orderItemIds := firedOrderItemIds(orderItemPack.OrderItems); len(orderItemIds) > 0 {
+			go printOrderItems(orderItemIds)
 		}
-		config.GetDB().Create(&orderItemPack.OrderItems)
-		defer cancel()
 		c.JSON(http.StatusOK, fmt.Sprintf("orderItems Created successfully for orderId : %v", orderId))
 	}
 }
+
+// createOrderItems prices, routes and stores the items on the order, then lets the kitchen know about them.
+// Items of course 0 are fired straight away, later courses wait for the course to be fired.
+func createOrderItems(tx *gorm.DB, orderId uint32, orderItems []models.OrderItem, canOverridePrice bool, userId uint32) error {
+	// items added once the bill is out would be on none of its invoices
+	if _, err := lockOpenOrder(tx, orderId); err != nil {
+		return err
+	}
+	for i := range orderItems {
+		orderItems[i].OrderId = orderId
+		orderItems[i].CreatedAt = time.Now()
+		orderItems[i].UpdatedAt = time.Now()
+		orderItems[i].PrepStatus = models.PrepQueued
+		orderItems[i].BumpedAt = nil
+		orderItems[i].FiredAt = nil
+		if orderItems[i].Course == 0 {
+			firedAt := time.Now()
+			orderItems[i].FiredAt = &firedAt
+		}
+		requestedPrice := orderItems[i].UnitPrice
+		if err := priceOrderItem(tx, &orderItems[i], requestedPrice, canOverridePrice, userId); err != nil {
+			return err
+		}
+		if err := selectModifiers(tx, &orderItems[i]); err != nil {
+			return err
+		}
+		if err := routeOrderItem(tx, &orderItems[i]); err != nil {
+			return err
+		}
+	}
+	if err := tx.Create(&orderItems).Error; err != nil {
+		return err
+	}
+	for _, orderItem := range orderItems {
+		if err := kitchen.Publish(tx, kitchen.Event{Type: kitchen.ItemCreated, OrderItemId: orderItem.ID, OrderId: orderItem.OrderId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firedOrderItemIds returns the ids of the items that went to the kitchen straight away.
+func firedOrderItemIds(orderItems []models.OrderItem) []uint32 {
+	orderItemIds := make([]uint32, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		if orderItem.FiredAt != nil {
+			orderItemIds = append(orderItemIds, orderItem.ID)
+		}
+	}
+	return orderItemIds
+}
+
+// replaceOrderItemModifiers swaps the stored modifiers of the item for the ones currently picked on it.
+func replaceOrderItemModifiers(tx *gorm.DB, item *models.OrderItem) error {
+	if err := tx.Where("order_item_id = ?", item.ID).Delete(&models.OrderItemModifier{}).Error; err != nil {
+		return err
+	}
+	for i := range item.Modifiers {
+		item.Modifiers[i].OrderItemId = item.ID
+	}
+	if len(item.Modifiers) == 0 {
+		return nil
+	}
+	return tx.Create(&item.Modifiers).Error
+}
+
+// orderItemErrorStatus maps an error from pricing an order item or picking its modifiers
+// to the status code sent to the client.
+func orderItemErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrFoodNotFound), errors.Is(err, ErrFoodVariantNotFound), errors.Is(err, ErrModifierNotFound),
+		errors.Is(err, ErrKitchenStationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFoodVariantRequired), errors.Is(err, ErrInvalidPriceOverride), errors.Is(err, ErrModifierChoice),
+		errors.Is(err, ErrInvalidOrderItem):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrPriceOverrideForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrOrderBilled), errors.Is(err, ErrOrderNotOpen), errors.Is(err, ErrOrderItemFired):
+		return http.StatusConflict
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
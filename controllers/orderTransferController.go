@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/kitchen"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOrderNotOpen is returned when moving items out of or into an order that was closed, cancelled, voided or merged.
+	ErrOrderNotOpen = errors.New("the order is no longer open")
+	// ErrOrderBilled is returned when moving items out of or into an order that already has an invoice.
+	ErrOrderBilled = errors.New("the order has already been billed")
+	// ErrOrderItemNotInOrder is returned when moving order items that are not part of the order they are moved from.
+	ErrOrderItemNotInOrder = errors.New("order item is not part of the order")
+	// ErrNothingToMerge is returned when a merge names no open order other than the one merged into.
+	ErrNothingToMerge = errors.New("there is no other open order to merge")
+	// ErrSplitTakesEverything is returned when a split would leave the original order without any items.
+	ErrSplitTakesEverything = errors.New("a split has to leave at least one item on the order")
+	// ErrTableNotFound is returned when moving an order to a table that does not exist.
+	ErrTableNotFound = errors.New("table not found")
+	// ErrOrderHasNoTable is returned when giving a takeaway or delivery order a table.
+	ErrOrderHasNoTable = errors.New("only dine-in orders are served at a table")
+	// ErrOrderTypeMismatch is returned when merging orders of different types.
+	ErrOrderTypeMismatch = errors.New("only orders of the same type can be merged")
+)
+
+// TransferOrderRequest moves an order to another table. When OrderItemIds only names some of its items,
+// just those move, into the table's open order or a new one.
+type TransferOrderRequest struct {
+	TableId      uint32   `json:"tableId" validate:"required"`
+	OrderItemIds []uint32 `json:"orderItemIds"`
+}
+
+// MergeOrdersRequest names the orders merged into another one, either by id or as every open order of a table.
+type MergeOrdersRequest struct {
+	OrderIds []uint32 `json:"orderIds" validate:"required_without=TableId"`
+	TableId  uint32   `json:"tableId" validate:"required_without=OrderIds"`
+}
+
+// SplitOrderRequest moves order items into a new order, on the same table unless TableId is sent.
+type SplitOrderRequest struct {
+	OrderItemIds []uint32 `json:"orderItemIds" validate:"required,min=1"`
+	TableId      uint32   `json:"tableId"`
+}
+
+// TransferOrder moves an order, or some of its items, to another table.
+func TransferOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request TransferOrderRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Transfer invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		orderId := c.Param("order_id")
+		userId := currentUserId(c)
+		var from, to models.Order
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := checkTable(tx, request.TableId); err != nil {
+				return err
+			}
+			var err error
+			if from, err = lockOpenOrder(tx, orderId); err != nil {
+				return err
+			}
+			if from.Type != models.DineIn {
+				return ErrOrderHasNoTable
+			}
+			itemIds, err := orderItemIdsOf(tx, from.ID)
+			if err != nil {
+				return err
+			}
+			if len(request.OrderItemIds) == 0 || coversAll(request.OrderItemIds, itemIds) {
+				if err := transferOrder(tx, &from, request.TableId, userId); err != nil {
+					return err
+				}
+				to = from
+				return publishItemsMoved(tx, itemIds, from.ID)
+			}
+
+			if to, err = openOrderAt(tx, request.TableId, from); err != nil {
+				return err
+			}
+			if to.ID == 0 {
+				note := fmt.Sprintf("items moved from order %d", from.ID)
+				if to, err = createOrderLike(tx, from, &request.TableId, userId, note); err != nil {
+					return err
+				}
+			}
+			return moveOrderItems(tx, &from, &to, request.OrderItemIds, userId)
+		})
+		if err != nil {
+			c.JSON(orderMoveErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to})
+	}
+}
+
+// MergeOrders moves every item of the named orders into the order and marks them merged.
+func MergeOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request MergeOrdersRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Merge invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		orderId := c.Param("order_id")
+		userId := currentUserId(c)
+		var target models.Order
+		var merged []models.Order
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			if target, err = lockOpenOrder(tx, orderId); err != nil {
+				return err
+			}
+
+			sourceIds := request.OrderIds
+			if request.TableId != 0 {
+				if err := checkTable(tx, request.TableId); err != nil {
+					return err
+				}
+				err := tx.Model(&models.Order{}).
+					Where("table_id = ? AND status NOT IN ?", request.TableId, closedOrderStatuses).
+					Pluck("id", &sourceIds).Error
+				if err != nil {
+					return err
+				}
+			}
+			sourceIds = uniqueIds(sourceIds)
+			sort.Slice(sourceIds, func(i, j int) bool { return sourceIds[i] < sourceIds[j] })
+
+			for _, sourceId := range sourceIds {
+				if sourceId == target.ID {
+					continue
+				}
+				source, err := lockOpenOrder(tx, sourceId)
+				if err != nil {
+					return err
+				}
+				if err := mergeOrder(tx, &source, &target, userId); err != nil {
+					return err
+				}
+				merged = append(merged, source)
+			}
+			if len(merged) == 0 {
+				return ErrNothingToMerge
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(orderMoveErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"order": target, "merged": merged})
+	}
+}
+
+// SplitOrder moves some of an order's items into a new order.
+func SplitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request SplitOrderRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Split invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		orderId := c.Param("order_id")
+		userId := currentUserId(c)
+		var from, to models.Order
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			if from, err = lockOpenOrder(tx, orderId); err != nil {
+				return err
+			}
+			tableId := from.TableId
+			if request.TableId != 0 {
+				if from.Type != models.DineIn {
+					return ErrOrderHasNoTable
+				}
+				if err := checkTable(tx, request.TableId); err != nil {
+					return err
+				}
+				tableId = &request.TableId
+			}
+			itemIds, err := orderItemIdsOf(tx, from.ID)
+			if err != nil {
+				return err
+			}
+			if coversAll(request.OrderItemIds, itemIds) {
+				return ErrSplitTakesEverything
+			}
+
+			note := fmt.Sprintf("split from order %d", from.ID)
+			if to, err = createOrderLike(tx, from, tableId, userId, note); err != nil {
+				return err
+			}
+			return moveOrderItems(tx, &from, &to, request.OrderItemIds, userId)
+		})
+		if err != nil {
+			c.JSON(orderMoveErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to})
+	}
+}
+
+// lockOpenOrder reads the order for update and makes sure items can still be moved out of it or into it.
+func lockOpenOrder(tx *gorm.DB, orderId interface{}) (models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderId).First(&order).Error; err != nil {
+		return order, err
+	}
+	if order.Status.IsTerminal() {
+		return order, fmt.Errorf("%w: order %d is %s", ErrOrderNotOpen, order.ID, order.Status)
+	}
+	var invoiceCount int64
+	if err := tx.Model(&models.Invoice{}).Where("order_id = ?", order.ID).Count(&invoiceCount).Error; err != nil {
+		return order, err
+	}
+	if invoiceCount > 0 {
+		return order, fmt.Errorf("%w: order %d", ErrOrderBilled, order.ID)
+	}
+	return order, nil
+}
+
+// openOrderAt returns the earliest open order on the table that items can be moved into, leaving out
+// the order they come from, or an order with no id when there is none.
+func openOrderAt(tx *gorm.DB, tableId uint32, from models.Order) (models.Order, error) {
+	var candidateIds []uint32
+	err := tx.Model(&models.Order{}).
+		Where("table_id = ? AND id <> ? AND status NOT IN ?", tableId, from.ID, closedOrderStatuses).
+		Order("order_date asc, id asc").
+		Pluck("id", &candidateIds).Error
+	if err != nil {
+		return models.Order{}, err
+	}
+	for _, candidateId := range candidateIds {
+		order, err := lockOpenOrder(tx, candidateId)
+		if errors.Is(err, ErrOrderBilled) || errors.Is(err, ErrOrderNotOpen) {
+			continue
+		}
+		return order, err
+	}
+	return models.Order{}, nil
+}
+
+// createOrderLike opens a new order on the table for items taken out of from, picking up where from is in its lifecycle.
+// Takeaway and delivery details are carried over, the delivery fee stays with the original order.
+func createOrderLike(tx *gorm.DB, from models.Order, tableId *uint32, userId uint32, note string) (models.Order, error) {
+	now := time.Now()
+	order := models.Order{
+		OrderDate:       now,
+		Status:          from.Status,
+		Type:            from.Type,
+		TableId:         tableId,
+		UserId:          userId,
+		CustomerName:    from.CustomerName,
+		CustomerPhone:   from.CustomerPhone,
+		PickupAt:        from.PickupAt,
+		DeliveryAddress: from.DeliveryAddress,
+		DriverId:        from.DriverId,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return order, err
+	}
+	err := recordOrderHistory(tx, models.OrderHistory{
+		OrderId:        order.ID,
+		Action:         models.OrderHistoryStatus,
+		ToStatus:       order.Status,
+		Note:           note,
+		RelatedOrderId: &from.ID,
+		UserId:         userId,
+	})
+	return order, err
+}
+
+// transferOrder moves the whole order to another table.
+func transferOrder(tx *gorm.DB, order *models.Order, tableId uint32, userId uint32) error {
+	if order.Type != models.DineIn {
+		return ErrOrderHasNoTable
+	}
+	if order.TableId != nil && *order.TableId == tableId {
+		return nil
+	}
+	now := time.Now()
+	err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Updates(map[string]interface{}{"table_id": tableId, "updated_at": now}).Error
+	if err != nil {
+		return err
+	}
+	fromTableId := order.TableId
+	order.TableId = &tableId
+	order.UpdatedAt = now
+	return recordOrderHistory(tx, models.OrderHistory{
+		OrderId:     order.ID,
+		Action:      models.OrderHistoryTransferred,
+		FromTableId: fromTableId,
+		ToTableId:   &tableId,
+		UserId:      userId,
+	})
+}
+
+// moveOrderItems moves the order items from one order to the other, recording the move on both.
+func moveOrderItems(tx *gorm.DB, from *models.Order, to *models.Order, orderItemIds []uint32, userId uint32) error {
+	orderItemIds = uniqueIds(orderItemIds)
+	now := time.Now()
+	result := tx.Model(&models.OrderItem{}).
+		Where("order_id = ? AND id IN ?", from.ID, orderItemIds).
+		Updates(map[string]interface{}{"order_id": to.ID, "updated_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(orderItemIds)) {
+		return fmt.Errorf("%w: order %d", ErrOrderItemNotInOrder, from.ID)
+	}
+
+	note := fmt.Sprintf("order items %s", joinIds(orderItemIds))
+	err := recordOrderHistory(tx, models.OrderHistory{
+		OrderId:        from.ID,
+		Action:         models.OrderHistoryItemsMoved,
+		Note:           note,
+		FromTableId:    from.TableId,
+		ToTableId:      to.TableId,
+		RelatedOrderId: &to.ID,
+		UserId:         userId,
+	})
+	if err != nil {
+		return err
+	}
+	err = recordOrderHistory(tx, models.OrderHistory{
+		OrderId:        to.ID,
+		Action:         models.OrderHistoryItemsMoved,
+		Note:           note,
+		FromTableId:    from.TableId,
+		ToTableId:      to.TableId,
+		RelatedOrderId: &from.ID,
+		UserId:         userId,
+	})
+	if err != nil {
+		return err
+	}
+	return publishItemsMoved(tx, orderItemIds, to.ID)
+}
+
+// mergeOrder moves every item of source into target and marks source merged into it.
+// target keeps its status, the kitchen goes on tracking each item on its own.
+func mergeOrder(tx *gorm.DB, source *models.Order, target *models.Order, userId uint32) error {
+	if source.Type != target.Type {
+		return fmt.Errorf("%w: order %d is %s, order %d is %s", ErrOrderTypeMismatch, source.ID, source.Type, target.ID, target.Type)
+	}
+	itemIds, err := orderItemIdsOf(tx, source.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if len(itemIds) > 0 {
+		err := tx.Model(&models.OrderItem{}).Where("order_id = ?", source.ID).
+			Updates(map[string]interface{}{"order_id": target.ID, "updated_at": now}).Error
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Model(&models.Order{}).Where("id = ?", source.ID).
+		Updates(map[string]interface{}{"status": models.OrderMerged, "merged_into_id": target.ID, "updated_at": now}).Error
+	if err != nil {
+		return err
+	}
+	previous := source.Status
+	source.Status = models.OrderMerged
+	source.MergedIntoId = &target.ID
+	source.UpdatedAt = now
+
+	note := fmt.Sprintf("order items %s", joinIds(itemIds))
+	err = recordOrderHistory(tx, models.OrderHistory{
+		OrderId:        source.ID,
+		Action:         models.OrderHistoryMerged,
+		FromStatus:     previous,
+		ToStatus:       models.OrderMerged,
+		Note:           note,
+		FromTableId:    source.TableId,
+		ToTableId:      target.TableId,
+		RelatedOrderId: &target.ID,
+		UserId:         userId,
+	})
+	if err != nil {
+		return err
+	}
+	err = recordOrderHistory(tx, models.OrderHistory{
+		OrderId:        target.ID,
+		Action:         models.OrderHistoryMerged,
+		Note:           note,
+		FromTableId:    source.TableId,
+		ToTableId:      target.TableId,
+		RelatedOrderId: &source.ID,
+		UserId:         userId,
+	})
+	if err != nil {
+		return err
+	}
+	return publishItemsMoved(tx, itemIds, target.ID)
+}
+
+// publishItemsMoved tells the kitchen displays the items now belong to another order or table.
+func publishItemsMoved(tx *gorm.DB, orderItemIds []uint32, orderId uint32) error {
+	events := make([]kitchen.Event, 0, len(orderItemIds))
+	for _, orderItemId := range orderItemIds {
+		events = append(events, kitchen.Event{Type: kitchen.ItemUpdated, OrderItemId: orderItemId, OrderId: orderId})
+	}
+	return kitchen.Publish(tx, events...)
+}
+
+func checkTable(tx *gorm.DB, tableId uint32) error {
+	var count int64
+	if err := tx.Model(&models.Table{}).Where("id = ?", tableId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrTableNotFound, tableId)
+	}
+	return nil
+}
+
+func orderItemIdsOf(tx *gorm.DB, orderId uint32) ([]uint32, error) {
+	var itemIds []uint32
+	err := tx.Model(&models.OrderItem{}).Where("order_id = ?", orderId).Order("id asc").Pluck("id", &itemIds).Error
+	return itemIds, err
+}
+
+// coversAll reports whether ids names every one of all.
+func coversAll(ids []uint32, all []uint32) bool {
+	named := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		named[id] = true
+	}
+	for _, id := range all {
+		if !named[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func uniqueIds(ids []uint32) []uint32 {
+	seen := make(map[uint32]bool, len(ids))
+	unique := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func joinIds(ids []uint32) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orderMoveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrTableNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrOrderNotOpen), errors.Is(err, ErrOrderBilled), errors.Is(err, ErrNothingToMerge):
+		return http.StatusConflict
+	case errors.Is(err, ErrOrderItemNotInOrder), errors.Is(err, ErrSplitTakesEverything), errors.Is(err, ErrOrderHasNoTable), errors.Is(err, ErrOrderTypeMismatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,556 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/KhetwalDevesh/restaurant-management/payments"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrInvoiceSettled is returned when a payment is taken for an invoice that is already paid.
+	ErrInvoiceSettled = errors.New("invoice is already paid")
+	// ErrPaymentExceedsBalance is returned when a payment is more than what is left to pay on the invoice.
+	ErrPaymentExceedsBalance = errors.New("payment exceeds the invoice balance")
+	// ErrRefundExceedsPayment is returned when a refund gives back more than was taken with the payment.
+	ErrRefundExceedsPayment = errors.New("refund exceeds what is left of the payment")
+	// ErrIdempotencyKeyReused is returned when an idempotency key already used for one invoice is sent for another.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for another invoice")
+	// ErrPaymentFailed is returned when a payment is retried with the idempotency key of a payment the provider turned down.
+	ErrPaymentFailed = errors.New("the payment with this idempotency key was declined, retry with a new key")
+	// ErrProviderPending is returned when the payment provider could not be reached or did not answer in time,
+	// leaving the card payment or refund pending until it is retried.
+	ErrProviderPending = errors.New("the payment provider did not answer")
+)
+
+// providerTimeout bounds a call to the payment provider. Calls don't follow the request's context,
+// so a client hanging up doesn't leave a charge or refund half done.
+const providerTimeout = 30 * time.Second
+
+// PaymentRequest is the body accepted when taking a payment. An Amount of 0 pays off the whole balance.
+// Retrying with the same IdempotencyKey, sent in the body or the Idempotency-Key header, never takes
+// the payment twice. Card payments go through the payment provider with the CardToken.
+type PaymentRequest struct {
+	Method         models.PaymentMethod `json:"method" validate:"omitempty,oneof=card cash"`
+	Amount         float64              `json:"amount" validate:"gte=0"`
+	Tip            float64              `json:"tip" validate:"gte=0"`
+	Reference      string               `json:"reference"`
+	CardToken      string               `json:"cardToken"`
+	IdempotencyKey string               `json:"idempotencyKey"`
+}
+
+// RefundRequest is the body accepted when refunding a payment. Leaving out both Amount and Tip
+// refunds everything that is left of the payment.
+type RefundRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"`
+	Tip    float64 `json:"tip" validate:"gte=0"`
+	Reason string  `json:"reason" validate:"required"`
+}
+
+// GetInvoicePayments lists the payments and refunds recorded against an invoice.
+func GetInvoicePayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invoiceId := c.Param("invoice_id")
+		var ledger []models.Payment
+		if err := config.GetDB().Where("invoice_id = ?", invoiceId).Order("id asc").Find(&ledger).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the invoice payments"})
+			return
+		}
+		c.JSON(http.StatusOK, ledger)
+	}
+}
+
+// RecordPayment takes a payment, with an optional tip, towards an invoice's balance.
+func RecordPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request PaymentRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Payment invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		invoiceId := c.Param("invoice_id")
+		var invoice models.Invoice
+		if err := config.GetDB().Where("id = ?", invoiceId).First(&invoice).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		invoiceView, err := invoiceViewOf(&invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
+		}
+
+		payment := models.Payment{
+			Method:    request.Method,
+			Amount:    toFixed(request.Amount, 2),
+			Tip:       toFixed(request.Tip, 2),
+			Reference: request.Reference,
+			UserId:    currentUserId(c),
+		}
+		if payment.Method == "" {
+			payment.Method = invoice.PaymentMethod
+		}
+		if request.IdempotencyKey == "" {
+			request.IdempotencyKey = c.GetHeader("Idempotency-Key")
+		}
+		if request.IdempotencyKey != "" {
+			payment.IdempotencyKey = &request.IdempotencyKey
+		}
+		var recorded *models.Payment
+		err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var err error
+			recorded, err = recordPayment(tx, invoice.ID, invoiceView.TotalAmount, payment)
+			return err
+		})
+		if err == nil {
+			err = chargeCard(recorded, invoiceView.TotalAmount, request.CardToken)
+		}
+		if err != nil {
+			c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		var paidInvoice models.Invoice
+		if err := config.GetDB().Where("id = ?", invoice.ID).First(&paidInvoice).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the invoice"})
+			return
+		}
+		invoiceView, err = invoiceViewOf(&paidInvoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
+		}
+		c.JSON(http.StatusOK, invoiceView)
+	}
+}
+
+// RefundPayment gives back all or part of a payment, the tip included, in the method it was taken with.
+func RefundPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request RefundRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Refund invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		paymentId := c.Param("payment_id")
+		var payment models.Payment
+		if err := config.GetDB().Where("id = ? AND kind = ?", paymentId, models.PaymentTender).First(&payment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			return
+		}
+		var invoice models.Invoice
+		if err := config.GetDB().Where("id = ?", payment.InvoiceId).First(&invoice).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		invoiceView, err := invoiceViewOf(&invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
+		}
+
+		var refund models.Payment
+		err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+			locked, ledger, err := lockInvoiceLedger(tx, invoice.ID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("id = ?", payment.ID).First(&payment).Error; err != nil {
+				return err
+			}
+			switch payment.ProviderStatus {
+			case models.ProviderFailed:
+				return fmt.Errorf("%w: payment %d was declined", ErrRefundExceedsPayment, payment.ID)
+			case models.ProviderPending:
+				return fmt.Errorf("%w: payment %d is still being charged", ErrRefundExceedsPayment, payment.ID)
+			}
+			// what is left of the payment once earlier refunds of it are taken off
+			amountLeft, tipLeft := payment.Amount, payment.Tip
+			for _, entry := range ledger {
+				if entry.Kind != models.PaymentRefund || entry.RefundOfId == nil || *entry.RefundOfId != payment.ID || entry.Failed() {
+					continue
+				}
+				if entry.ProviderStatus == models.ProviderPending {
+					// a refund the provider didn't answer for is given again before any new one
+					refund = entry
+					return nil
+				}
+				amountLeft -= entry.Amount
+				tipLeft -= entry.Tip
+			}
+			amountLeft, tipLeft = toFixed(amountLeft, 2), toFixed(tipLeft, 2)
+
+			refund = models.Payment{
+				InvoiceId:  invoice.ID,
+				Kind:       models.PaymentRefund,
+				Method:     payment.Method,
+				Amount:     toFixed(request.Amount, 2),
+				Tip:        toFixed(request.Tip, 2),
+				Reason:     request.Reason,
+				RefundOfId: &payment.ID,
+				UserId:     currentUserId(c),
+				CreatedAt:  time.Now(),
+			}
+			if refund.Amount == 0 && refund.Tip == 0 {
+				refund.Amount, refund.Tip = amountLeft, tipLeft
+			}
+			if refund.Amount > amountLeft || refund.Tip > tipLeft {
+				return fmt.Errorf("%w: %.2f and a tip of %.2f are left", ErrRefundExceedsPayment, amountLeft, tipLeft)
+			}
+			if refund.Amount == 0 && refund.Tip == 0 {
+				return fmt.Errorf("%w: nothing is left of payment %d", ErrRefundExceedsPayment, payment.ID)
+			}
+			if payment.ProviderTransactionId != "" {
+				refund.Provider = payment.Provider
+				refund.ProviderStatus = models.ProviderPending
+			}
+			if err := validate.Struct(refund); err != nil {
+				return err
+			}
+			if err := tx.Create(&refund).Error; err != nil {
+				return err
+			}
+			return updatePaymentStatus(tx, locked, invoiceView.TotalAmount, append(ledger, refund))
+		})
+		if err == nil && refund.ProviderStatus == models.ProviderPending {
+			err = refundCard(&payment, &refund, invoiceView.TotalAmount)
+		}
+		if err != nil {
+			c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, refund)
+	}
+}
+
+// PaymentWebhook takes notifications from a payment provider about its transactions and keeps
+// the provider status of the matching payments up to date.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, err := payments.Get(c.Param("provider"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		event, err := provider.VerifyWebhook(c.Request.Header, body)
+		if err != nil {
+			if errors.Is(err, payments.ErrInvalidSignature) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = config.GetDB().Model(&models.Payment{}).
+			Where("provider = ? AND provider_transaction_id = ? AND kind = ? AND provider_status <> ?",
+				provider.Name(), event.TransactionId, models.PaymentTender, models.ProviderFailed).
+			Update("provider_status", event.Status).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record the webhook"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook received"})
+	}
+}
+
+// recordPayment adds a payment to the invoice's ledger and brings the invoice's payment status up to date.
+// A payment with an Amount of 0 pays off whatever is left of total. Card payments are recorded as pending
+// with the payment provider, given an idempotency key when they have none, and returned to be charged with
+// chargeCard once the transaction is committed. They don't count as paid until the provider took the money.
+// A payment whose idempotency key was already used is not taken again, the earlier payment is returned instead.
+func recordPayment(tx *gorm.DB, invoiceId uint32, total float64, payment models.Payment) (*models.Payment, error) {
+	invoice, ledger, err := lockInvoiceLedger(tx, invoiceId)
+	if err != nil {
+		return nil, err
+	}
+	if payment.IdempotencyKey != nil {
+		var earlier models.Payment
+		err := tx.Where("idempotency_key = ?", *payment.IdempotencyKey).First(&earlier).Error
+		if err == nil {
+			if earlier.InvoiceId != invoice.ID {
+				return nil, ErrIdempotencyKeyReused
+			}
+			if earlier.Failed() {
+				return nil, ErrPaymentFailed
+			}
+			return &earlier, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if invoice.PaymentStatus.Settled() {
+		return nil, ErrInvoiceSettled
+	}
+	// card payments still waiting on the provider hold their share of the balance
+	paid, _ := ledgerTotals(ledger)
+	balance := toFixed(total-paid-pendingTotal(ledger), 2)
+	if payment.Amount == 0 {
+		payment.Amount = balance
+	}
+	if payment.Amount > balance {
+		return nil, fmt.Errorf("%w: %.2f is left to pay", ErrPaymentExceedsBalance, balance)
+	}
+
+	if payment.Amount == 0 && payment.Tip == 0 {
+		return nil, updatePaymentStatus(tx, invoice, total, ledger)
+	}
+	payment.InvoiceId = invoice.ID
+	payment.Kind = models.PaymentTender
+	payment.CreatedAt = time.Now()
+	if payment.Method == models.Card {
+		provider, err := payments.Default()
+		if err != nil {
+			return nil, err
+		}
+		payment.Provider = provider.Name()
+		payment.ProviderStatus = models.ProviderPending
+	}
+	if err := validate.Struct(payment); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return nil, err
+	}
+	if payment.Method == models.Card && payment.IdempotencyKey == nil {
+		// the provider needs a key to charge the card once however often it is retried
+		key := fmt.Sprintf("payment-%d", payment.ID)
+		payment.IdempotencyKey = &key
+		if err := tx.Model(&payment).Update("idempotency_key", key).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &payment, updatePaymentStatus(tx, invoice, total, append(ledger, payment))
+}
+
+// chargeCard charges a card payment recordPayment left pending, outside of any transaction so the
+// invoice isn't kept locked while the provider is called. The payment is authorized under its
+// idempotency key, so charging it again never takes the money twice, and then captured. Payments
+// the provider turns down are marked failed, those it didn't answer for stay pending to be charged
+// again with the same key. Other payments were already taken and are left alone.
+func chargeCard(payment *models.Payment, total float64, cardToken string) error {
+	if payment == nil || payment.ProviderStatus != models.ProviderPending {
+		return nil
+	}
+	provider, err := payments.Get(payment.Provider)
+	if err != nil {
+		// the provider it was recorded for is gone, it won't ever be charged
+		if settleErr := settleProviderEntry(payment, total, payments.Transaction{}, err); settleErr != nil {
+			return settleErr
+		}
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+	request := payments.AuthorizeRequest{
+		Amount:         toFixed(payment.Amount+payment.Tip, 2),
+		CardToken:      cardToken,
+		Reference:      fmt.Sprintf("invoice-%d", payment.InvoiceId),
+		IdempotencyKey: *payment.IdempotencyKey,
+	}
+	transaction, err := provider.Authorize(ctx, request)
+	if err == nil {
+		var captured payments.Transaction
+		if captured, err = provider.Capture(ctx, transaction.Id); err == nil {
+			transaction = captured
+		} else if payments.Declined(err) {
+			voidCard(ctx, provider, transaction.Id)
+		}
+	}
+	if settleErr := settleProviderEntry(payment, total, transaction, err); settleErr != nil {
+		log.Printf("payments: payment %d got %s from %s transaction %q but it was not recorded: %v",
+			payment.ID, transaction.Status, provider.Name(), transaction.Id, settleErr)
+		return settleErr
+	}
+	if err != nil && !payments.Declined(err) {
+		return fmt.Errorf("%w, payment %d is pending, retry with the idempotency key %q: %v",
+			ErrProviderPending, payment.ID, *payment.IdempotencyKey, err)
+	}
+	return err
+}
+
+// refundCard gives a card refund recorded as pending back through the payment provider, outside of any
+// transaction. The refund's id is its idempotency key with the provider so it is never given twice,
+// a refund the provider didn't answer for stays pending and is given again with the next refund request.
+func refundCard(payment *models.Payment, refund *models.Payment, total float64) error {
+	var transaction payments.Transaction
+	provider, err := payments.Get(refund.Provider)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+		defer cancel()
+		amount := toFixed(refund.Amount+refund.Tip, 2)
+		transaction, err = provider.Refund(ctx, payment.ProviderTransactionId, amount, fmt.Sprintf("refund-%d", refund.ID))
+	}
+	if settleErr := settleProviderEntry(refund, total, transaction, err); settleErr != nil {
+		log.Printf("payments: refund %d got %q from provider transaction %q but it was not recorded: %v",
+			refund.ID, transaction.Status, transaction.Id, settleErr)
+		return settleErr
+	}
+	if err != nil && !payments.Declined(err) && !errors.Is(err, payments.ErrUnknownProvider) {
+		return fmt.Errorf("%w, refund %d is pending, refund payment %d again to finish it: %v",
+			ErrProviderPending, refund.ID, payment.ID, err)
+	}
+	return err
+}
+
+// voidCard lets go of an authorization that won't be captured. A failed void is only logged,
+// the provider lets the hold run out on its own.
+func voidCard(ctx context.Context, provider payments.Provider, transactionId string) {
+	if _, err := provider.Void(ctx, transactionId); err != nil {
+		log.Printf("payments: voiding %s transaction %s failed: %v", provider.Name(), transactionId, err)
+	}
+}
+
+// settleProviderEntry records the provider's answer on a card payment or refund recorded as pending and
+// brings the invoice's payment status up to date. Entries the provider turned down are marked failed,
+// which keeps them off the invoice's totals for good. Entries it didn't answer for are left pending.
+func settleProviderEntry(entry *models.Payment, total float64, transaction payments.Transaction, providerErr error) error {
+	if providerErr != nil && !payments.Declined(providerErr) && !errors.Is(providerErr, payments.ErrUnknownProvider) {
+		return nil
+	}
+	if transaction.Id != "" {
+		entry.ProviderTransactionId = transaction.Id
+		entry.ProviderStatus = string(transaction.Status)
+	}
+	if providerErr != nil {
+		entry.ProviderStatus = models.ProviderFailed
+	}
+	return config.GetDB().Transaction(func(tx *gorm.DB) error {
+		invoice, ledger, err := lockInvoiceLedger(tx, entry.InvoiceId)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(entry).Where("provider_status = ?", models.ProviderPending).Updates(map[string]interface{}{
+			"provider_transaction_id": entry.ProviderTransactionId,
+			"provider_status":         entry.ProviderStatus,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for i := range ledger {
+			if ledger[i].ID == entry.ID {
+				ledger[i] = *entry
+			}
+		}
+		return updatePaymentStatus(tx, invoice, total, ledger)
+	})
+}
+
+// lockInvoiceLedger locks the invoice against concurrent payments and returns it with its ledger.
+func lockInvoiceLedger(tx *gorm.DB, invoiceId uint32) (*models.Invoice, []models.Payment, error) {
+	var invoice models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invoiceId).First(&invoice).Error; err != nil {
+		return nil, nil, err
+	}
+	var ledger []models.Payment
+	if err := tx.Where("invoice_id = ?", invoiceId).Order("id asc").Find(&ledger).Error; err != nil {
+		return nil, nil, err
+	}
+	return &invoice, ledger, nil
+}
+
+// updatePaymentStatus derives the invoice's payment status from its ledger and stores it.
+func updatePaymentStatus(tx *gorm.DB, invoice *models.Invoice, total float64, ledger []models.Payment) error {
+	paid, _ := ledgerTotals(ledger)
+	status := paymentStatusFor(invoice.PaymentStatus, paid, total)
+	if status == invoice.PaymentStatus {
+		return nil
+	}
+	invoice.PaymentStatus = status
+	invoice.UpdatedAt = time.Now()
+	return tx.Model(invoice).Updates(map[string]interface{}{"payment_status": status, "updated_at": invoice.UpdatedAt}).Error
+}
+
+// paymentStatusFor returns the payment status of an invoice in the current status once paid has been paid
+// towards its total. Once an invoice has been paid in full, refunds make it partially refunded or refunded
+// instead of taking it back to partially paid.
+func paymentStatusFor(current models.PaymentStatus, paid float64, total float64) models.PaymentStatus {
+	switch {
+	case current.Settled() && paid <= 0:
+		return models.Refunded
+	case current.Settled() && paid < total:
+		return models.PartiallyRefunded
+	case paid >= total:
+		return models.Paid
+	case paid > 0:
+		return models.PartiallyPaid
+	default:
+		return models.Pending
+	}
+}
+
+// ledgerTotals returns what was paid towards the invoice and the tips, both net of refunds. Card payments
+// and refunds count once the provider took or gave back the money, not while they are pending or failed.
+func ledgerTotals(ledger []models.Payment) (float64, float64) {
+	var paid, tips float64
+	for _, payment := range ledger {
+		if payment.Failed() || payment.ProviderStatus == models.ProviderPending {
+			continue
+		}
+		switch payment.Kind {
+		case models.PaymentTender:
+			paid += payment.Amount
+			tips += payment.Tip
+		case models.PaymentRefund:
+			paid -= payment.Amount
+			tips -= payment.Tip
+		}
+	}
+	return toFixed(paid, 2), toFixed(tips, 2)
+}
+
+// pendingTotal returns what card payments still waiting on the provider are for, tips left out.
+func pendingTotal(ledger []models.Payment) float64 {
+	var pending float64
+	for _, payment := range ledger {
+		if payment.Kind == models.PaymentTender && payment.ProviderStatus == models.ProviderPending {
+			pending += payment.Amount
+		}
+	}
+	return toFixed(pending, 2)
+}
+
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvoiceSettled), errors.Is(err, ErrIdempotencyKeyReused), errors.Is(err, ErrPaymentFailed):
+		return http.StatusConflict
+	case errors.Is(err, ErrPaymentExceedsBalance), errors.Is(err, ErrRefundExceedsPayment):
+		return http.StatusBadRequest
+	case errors.Is(err, payments.ErrDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, payments.ErrUnknownProvider), errors.Is(err, payments.ErrUnknownTransaction), errors.Is(err, payments.ErrInvalidTransition),
+		errors.Is(err, ErrProviderPending):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"testing"
+)
+
+func TestLedgerTotals(t *testing.T) {
+	cash := func(amount, tip float64) models.Payment {
+		return models.Payment{Kind: models.PaymentTender, Method: models.Cash, Amount: amount, Tip: tip}
+	}
+	card := func(kind models.PaymentKind, amount, tip float64, providerStatus string) models.Payment {
+		return models.Payment{Kind: kind, Method: models.Card, Amount: amount, Tip: tip, ProviderStatus: providerStatus}
+	}
+
+	cases := []struct {
+		name        string
+		ledger      []models.Payment
+		wantPaid    float64
+		wantTips    float64
+		wantPending float64
+	}{
+		{name: "empty ledger"},
+		{
+			name:     "payments and tips add up",
+			ledger:   []models.Payment{cash(10.10, 1), card(models.PaymentTender, 20.20, 2, "captured")},
+			wantPaid: 30.30,
+			wantTips: 3,
+		},
+		{
+			name:     "refunds come off what was paid",
+			ledger:   []models.Payment{cash(30, 3), card(models.PaymentRefund, 10, 1, "refunded")},
+			wantPaid: 20,
+			wantTips: 2,
+		},
+		{
+			name:     "failed payments and refunds don't count",
+			ledger:   []models.Payment{cash(30, 0), card(models.PaymentTender, 15, 0, models.ProviderFailed), card(models.PaymentRefund, 5, 0, models.ProviderFailed)},
+			wantPaid: 30,
+		},
+		{
+			name:        "pending payments are held apart",
+			ledger:      []models.Payment{cash(10, 0), card(models.PaymentTender, 15, 2, models.ProviderPending)},
+			wantPaid:    10,
+			wantPending: 15,
+		},
+		{
+			name:     "pending refunds don't count yet",
+			ledger:   []models.Payment{cash(30, 0), card(models.PaymentRefund, 10, 0, models.ProviderPending)},
+			wantPaid: 30,
+		},
+	}
+	for _, tc := range cases {
+		paid, tips := ledgerTotals(tc.ledger)
+		if paid != tc.wantPaid || tips != tc.wantTips {
+			t.Errorf("%s: got paid %v and tips %v, want %v and %v", tc.name, paid, tips, tc.wantPaid, tc.wantTips)
+		}
+		if pending := pendingTotal(tc.ledger); pending != tc.wantPending {
+			t.Errorf("%s: got pending %v, want %v", tc.name, pending, tc.wantPending)
+		}
+	}
+}
+
+func TestPaymentStatusFor(t *testing.T) {
+	cases := []struct {
+		name    string
+		current models.PaymentStatus
+		paid    float64
+		want    models.PaymentStatus
+	}{
+		{name: "nothing paid", current: models.Pending, paid: 0, want: models.Pending},
+		{name: "part paid", current: models.Pending, paid: 40, want: models.PartiallyPaid},
+		{name: "paid in full", current: models.PartiallyPaid, paid: 100, want: models.Paid},
+		{name: "overpaid", current: models.PartiallyPaid, paid: 120, want: models.Paid},
+		{name: "refunded before being paid in full", current: models.PartiallyPaid, paid: 0, want: models.Pending},
+		{name: "partial refund after being paid", current: models.Paid, paid: 60, want: models.PartiallyRefunded},
+		{name: "full refund after being paid", current: models.Paid, paid: 0, want: models.Refunded},
+		{name: "rest refunded after a partial refund", current: models.PartiallyRefunded, paid: 0, want: models.Refunded},
+		{name: "a refund that failed after being paid", current: models.PartiallyRefunded, paid: 100, want: models.Paid},
+	}
+	for _, tc := range cases {
+		if got := paymentStatusFor(tc.current, tc.paid, 100); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
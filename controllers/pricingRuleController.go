@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrFoodNotFound is returned when an order item refers to a food that does not exist.
+	ErrFoodNotFound = errors.New("food was not found")
+	// ErrFoodVariantNotFound is returned when an order item refers to a variant its food does not have.
+	ErrFoodVariantNotFound = errors.New("food variant was not found")
+	// ErrFoodVariantRequired is returned when a food defines variants but the order item does not pick one.
+	ErrFoodVariantRequired = errors.New("a variant has to be chosen for this food")
+	// ErrPriceOverrideForbidden is returned when a user without the override_prices permission tries to set the price of an order item.
+	ErrPriceOverrideForbidden = errors.New("your role does not have the override_prices permission")
+	// ErrInvalidPriceOverride is returned when an override asks for a price that can not be charged.
+	ErrInvalidPriceOverride = errors.New("price override can not be negative")
+)
+
+func GetPricingRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rules []*models.PricingRule
+		if err := config.GetDB().Order("id asc").Find(&rules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetPricingRules got error"})
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+func CreatePricingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rule models.PricingRule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		rule.CreatedAt = time.Now()
+		rule.UpdatedAt = time.Now()
+
+		// validate pricing rule data before storing it in db
+		if err := validate.Struct(rule); err != nil {
+			msg := fmt.Sprintf("Pricing rule invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Create(&rule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the pricing rule"})
+			return
+		}
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+func UpdatePricingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rule models.PricingRule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ruleID := c.Param("pricing_rule_id")
+		var existingRule models.PricingRule
+		if err := config.GetDB().Where("id = ?", ruleID).First(&existingRule).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+
+		if rule.Name != "" {
+			existingRule.Name = rule.Name
+		}
+		if rule.FoodId != nil {
+			existingRule.FoodId = rule.FoodId
+		}
+		if rule.MenuId != nil {
+			existingRule.MenuId = rule.MenuId
+		}
+		if rule.AdjustmentType != "" {
+			existingRule.AdjustmentType = rule.AdjustmentType
+		}
+		if rule.Amount != 0 {
+			existingRule.Amount = rule.Amount
+		}
+		if rule.StartsAt != nil {
+			existingRule.StartsAt = rule.StartsAt
+		}
+		if rule.EndsAt != nil {
+			existingRule.EndsAt = rule.EndsAt
+		}
+		if rule.DailyStart != "" {
+			existingRule.DailyStart = rule.DailyStart
+		}
+		if rule.DailyEnd != "" {
+			existingRule.DailyEnd = rule.DailyEnd
+		}
+		existingRule.UpdatedAt = time.Now()
+
+		// validate pricing rule data before storing it in db
+		if err := validate.Struct(existingRule); err != nil {
+			msg := fmt.Sprintf("Pricing rule invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Save(&existingRule).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Pricing rule update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingRule)
+	}
+}
+
+// resolveFoodPrice returns the price of the food, or of the variant when one is given,
+// at the given time after applying every pricing rule in effect.
+func resolveFoodPrice(tx *gorm.DB, food models.Food, variant *models.FoodVariant, at time.Time) (float64, error) {
+	var rules []models.PricingRule
+	// a rule with both a food and a menu only applies to that food while it is on that menu
+	err := tx.Where("(food_id = ? OR food_id IS NULL) AND (menu_id = ? OR menu_id IS NULL)", food.ID, food.MenuId).
+		Order("id asc").
+		Find(&rules).Error
+	if err != nil {
+		return 0, err
+	}
+	price := food.Price
+	if variant != nil {
+		price = variant.Price
+	}
+	for _, rule := range rules {
+		if rule.AppliesAt(at) {
+			price = rule.Apply(price)
+		}
+	}
+	return toFixed(price, 2), nil
+}
+
+// priceOrderItem sets the unit price of the item from its food and the pricing rules in effect,
+// ignoring whatever price the client sent. The requested price is only kept when the item carries
+// a PriceOverrideReason and the caller is allowed to override prices.
+func priceOrderItem(tx *gorm.DB, item *models.OrderItem, requestedPrice float64, canOverride bool, userId uint32) error {
+	var food models.Food
+	if err := tx.Where("id = ?", item.FoodId).First(&food).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %d", ErrFoodNotFound, item.FoodId)
+		}
+		return err
+	}
+
+	variant, err := orderItemVariant(tx, item)
+	if err != nil {
+		return err
+	}
+	item.VariantName = ""
+	if variant != nil {
+		item.VariantName = variant.Name
+	}
+
+	listPrice, err := resolveFoodPrice(tx, food, variant, time.Now())
+	if err != nil {
+		return err
+	}
+	item.ListPrice = listPrice
+	item.UnitPrice = listPrice
+	item.PriceOverriddenBy = nil
+	if item.PriceOverrideReason == "" {
+		return nil
+	}
+
+	if !canOverride {
+		return ErrPriceOverrideForbidden
+	}
+	if requestedPrice < 0 {
+		return ErrInvalidPriceOverride
+	}
+	item.UnitPrice = toFixed(requestedPrice, 2)
+	item.PriceOverriddenBy = &userId
+	return nil
+}
+
+// orderItemVariant loads the variant picked for the item, making sure one is picked whenever its food has variants.
+func orderItemVariant(tx *gorm.DB, item *models.OrderItem) (*models.FoodVariant, error) {
+	if item.FoodVariantId == nil {
+		var variantCount int64
+		if err := tx.Model(&models.FoodVariant{}).Where("food_id = ?", item.FoodId).Count(&variantCount).Error; err != nil {
+			return nil, err
+		}
+		if variantCount > 0 {
+			return nil, fmt.Errorf("%w: %d", ErrFoodVariantRequired, item.FoodId)
+		}
+		return nil, nil
+	}
+
+	var variant models.FoodVariant
+	err := tx.Where("id = ? AND food_id = ?", *item.FoodVariantId, item.FoodId).First(&variant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrFoodVariantNotFound, *item.FoodVariantId)
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
@@ -0,0 +1,329 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/KhetwalDevesh/restaurant-management/printer"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// PrinterUpdate is the body accepted when updating a printer, pointers tell unset fields from zero values.
+type PrinterUpdate struct {
+	Name    string             `json:"name"`
+	Address string             `json:"address"`
+	Kind    models.PrinterKind `json:"kind"`
+	Active  *bool              `json:"active"`
+}
+
+// PrintRequest picks the printer a job goes to, the first active printer of the right kind when left out.
+// OrderItemIds limits a kitchen ticket to some of the order's items.
+type PrintRequest struct {
+	PrinterId    uint32   `json:"printerId"`
+	OrderItemIds []uint32 `json:"orderItemIds"`
+}
+
+func GetPrinters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var printers []*models.Printer
+		if err := config.GetDB().Order("id asc").Find(&printers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetPrinters got error"})
+			return
+		}
+		c.JSON(http.StatusOK, printers)
+	}
+}
+
+func CreatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var newPrinter models.Printer
+		if err := c.BindJSON(&newPrinter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		newPrinter.Active = true
+		newPrinter.CreatedAt = time.Now()
+		newPrinter.UpdatedAt = time.Now()
+
+		if err := validate.Struct(newPrinter); err != nil {
+			msg := fmt.Sprintf("Printer invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Create(&newPrinter).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding the printer"})
+			return
+		}
+		c.JSON(http.StatusOK, newPrinter)
+	}
+}
+
+func UpdatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update PrinterUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		printerId := c.Param("printer_id")
+		var existingPrinter models.Printer
+		if err := config.GetDB().Where("id = ?", printerId).First(&existingPrinter).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Printer not found"})
+			return
+		}
+
+		if update.Name != "" {
+			existingPrinter.Name = update.Name
+		}
+		if update.Address != "" {
+			existingPrinter.Address = update.Address
+		}
+		if update.Kind != "" {
+			existingPrinter.Kind = update.Kind
+		}
+		if update.Active != nil {
+			existingPrinter.Active = *update.Active
+		}
+		existingPrinter.UpdatedAt = time.Now()
+
+		if err := validate.Struct(existingPrinter); err != nil {
+			msg := fmt.Sprintf("Printer invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Save(&existingPrinter).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Printer update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingPrinter)
+	}
+}
+
+// GetInvoiceEscPos renders the invoice's receipt as an ESC/POS byte stream, for clients printing it themselves.
+func GetInvoiceEscPos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := invoiceReceiptJob(c)
+		if !ok {
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"invoice-%s.bin\"", c.Param("invoice_id")))
+		c.Data(http.StatusOK, "application/octet-stream", job)
+	}
+}
+
+// PrintInvoice sends the invoice's receipt to a receipt printer.
+func PrintInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request PrintRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		target, err := pickPrinter(request.PrinterId, models.ReceiptPrinter)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		job, ok := invoiceReceiptJob(c)
+		if !ok {
+			return
+		}
+		if err := printer.Send(c.Request.Context(), target.Address, job); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("printer %s did not take the job: %v", target.Name, err)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt sent to " + target.Name})
+	}
+}
+
+// PrintKitchenTicket sends, or sends again, a kitchen ticket for an order's items to a kitchen printer.
+func PrintKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request PrintRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		target, err := pickPrinter(request.PrinterId, models.KitchenPrinter)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		query := config.GetDB().Where("order_items.order_id = ?", c.Param("order_id")).Order("order_items.id asc")
+		if len(request.OrderItemIds) > 0 {
+			query = query.Where("order_items.id IN ?", request.OrderItemIds)
+		}
+		orderItems, err := kitchenItems(query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the order items"})
+			return
+		}
+		if len(orderItems) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no order items to print"})
+			return
+		}
+
+		if err := printer.Send(c.Request.Context(), target.Address, printer.Ticket(kitchenTicketOf(orderItems))); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("printer %s did not take the job: %v", target.Name, err)})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket sent to " + target.Name})
+	}
+}
+
+// printOrderItems sends kitchen tickets for newly created order items. Items routed to a station with a
+// printer go to that printer, the rest go to the kitchen printers no station has, or every kitchen printer
+// when all of them belong to stations. It runs after the items are saved, so printers being offline only gets logged.
+func printOrderItems(orderItemIds []uint32) {
+	// it runs in its own goroutine, a panic here would take the whole server down
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("kitchen tickets: printing order items %v panicked: %v", orderItemIds, r)
+		}
+	}()
+	var printers []models.Printer
+	if err := config.GetDB().Where("kind = ? AND active = ?", models.KitchenPrinter, true).Order("id asc").Find(&printers).Error; err != nil {
+		log.Printf("kitchen tickets: listing printers: %v", err)
+		return
+	}
+	if len(printers) == 0 {
+		return
+	}
+	var stations []models.KitchenStation
+	if err := config.GetDB().Where("active = ? AND printer_id IS NOT NULL", true).Find(&stations).Error; err != nil {
+		log.Printf("kitchen tickets: listing kitchen stations: %v", err)
+		return
+	}
+	orderItems, err := kitchenItems(config.GetDB().Where("order_items.id IN ?", orderItemIds).Order("order_items.id asc"))
+	if err != nil || len(orderItems) == 0 {
+		log.Printf("kitchen tickets: loading order items %v: %v", orderItemIds, err)
+		return
+	}
+
+	printersById := make(map[uint32]models.Printer, len(printers))
+	for _, kitchenPrinter := range printers {
+		printersById[kitchenPrinter.ID] = kitchenPrinter
+	}
+	stationsById := make(map[uint32]models.KitchenStation, len(stations))
+	stationPrinters := make(map[uint32]bool)
+	for _, station := range stations {
+		if _, ok := printersById[*station.PrinterId]; ok {
+			stationsById[station.ID] = station
+			stationPrinters[*station.PrinterId] = true
+		}
+	}
+
+	var unrouted []*models.OrderItem
+	byStation := make(map[uint32][]*models.OrderItem)
+	for _, item := range orderItems {
+		if item.StationId != nil {
+			if _, ok := stationsById[*item.StationId]; ok {
+				byStation[*item.StationId] = append(byStation[*item.StationId], item)
+				continue
+			}
+		}
+		unrouted = append(unrouted, item)
+	}
+
+	send := func(target models.Printer, ticket printer.KitchenTicket) {
+		if err := printer.Send(context.Background(), target.Address, printer.Ticket(ticket)); err != nil {
+			log.Printf("kitchen tickets: printer %s: %v", target.Name, err)
+		}
+	}
+	for stationId, items := range byStation {
+		station := stationsById[stationId]
+		ticket := kitchenTicketOf(items)
+		ticket.Station = station.Name
+		send(printersById[*station.PrinterId], ticket)
+	}
+	if len(unrouted) > 0 {
+		ticket := kitchenTicketOf(unrouted)
+		fallback := make([]models.Printer, 0, len(printers))
+		for _, kitchenPrinter := range printers {
+			if !stationPrinters[kitchenPrinter.ID] {
+				fallback = append(fallback, kitchenPrinter)
+			}
+		}
+		if len(fallback) == 0 {
+			fallback = printers
+		}
+		for _, target := range fallback {
+			send(target, ticket)
+		}
+	}
+}
+
+func invoiceReceiptJob(c *gin.Context) ([]byte, bool) {
+	var invoice models.Invoice
+	if err := config.GetDB().Where("id = ?", c.Param("invoice_id")).First(&invoice).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return nil, false
+	}
+	invoiceView, err := invoiceViewOf(&invoice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+		return nil, false
+	}
+	return printer.Receipt(receiptOf(invoiceView, invoice.CreatedAt)), true
+}
+
+// pickPrinter returns printerId, or the first printer of the kind when it is 0. Either way the printer has
+// to be active and of the kind, so tickets never come out of the receipt printer and receipts never in the kitchen.
+func pickPrinter(printerId uint32, kind models.PrinterKind) (*models.Printer, error) {
+	var target models.Printer
+	query := config.GetDB().Where("active = ? AND kind = ?", true, kind)
+	if printerId != 0 {
+		query = query.Where("id = ?", printerId)
+	} else {
+		query = query.Order("id asc")
+	}
+	if err := query.First(&target).Error; err != nil {
+		return nil, fmt.Errorf("no active %s printer was found", kind)
+	}
+	return &target, nil
+}
+
+// kitchenTicketOf puts order items of one order on a kitchen ticket.
+func kitchenTicketOf(orderItems []*models.OrderItem) printer.KitchenTicket {
+	order := orderItems[0].Order
+	ticket := printer.KitchenTicket{
+		OrderId:   orderItems[0].OrderId,
+		OrderType: orderTypeLabel(order.Type),
+		Customer:  order.CustomerName,
+		PickupAt:  order.PickupAt,
+		PrintedAt: time.Now(),
+	}
+	if order.Table != nil {
+		ticket.TableNumber = order.Table.TableNumber
+	}
+	// tickets for a fired course say which course it is
+	ticket.Course = orderItems[0].Course
+	for _, item := range orderItems {
+		if item.Course != ticket.Course {
+			ticket.Course = 0
+		}
+	}
+	for _, item := range orderItems {
+		ticketItem := printer.TicketItem{Quantity: item.Quantity, Name: item.Food.Name, Seat: item.Seat, Notes: item.Notes}
+		if item.VariantName != "" {
+			ticketItem.Details = append(ticketItem.Details, item.VariantName)
+		}
+		for _, modifier := range item.Modifiers {
+			ticketItem.Details = append(ticketItem.Details, modifier.Name)
+		}
+		ticket.Items = append(ticket.Items, ticketItem)
+	}
+	return ticket
+}
@@ -0,0 +1,402 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPromotionUsedUp is returned when a promotion has reached its usage limit.
+	ErrPromotionUsedUp = errors.New("this promotion has been used up")
+	// ErrPromotionApplied is returned when a promotion is applied to an invoice that already has it.
+	ErrPromotionApplied = errors.New("this promotion is already applied to the invoice")
+	// ErrMinSpendNotMet is returned when an invoice comes to less than a promotion's minimum spend.
+	ErrMinSpendNotMet = errors.New("this promotion needs a minimum spend")
+	// ErrInvoiceNotPending is returned when discounting an invoice that is already paid.
+	ErrInvoiceNotPending = errors.New("discounts can only be applied to unpaid invoices")
+)
+
+// InvoiceDiscountLine is the amount one discount takes off an invoice.
+type InvoiceDiscountLine struct {
+	Id          uint32
+	PromotionId uint32
+	Name        string
+	Code        string
+	Scope       models.DiscountScope
+	Amount      float64
+}
+
+// PromotionUpdate is the body accepted when updating a promotion, pointers tell unset fields from zero values.
+type PromotionUpdate struct {
+	Name       string     `json:"name"`
+	Value      float64    `json:"value"`
+	MinSpend   *float64   `json:"minSpend"`
+	StartsAt   *time.Time `json:"startsAt"`
+	EndsAt     *time.Time `json:"endsAt"`
+	UsageLimit *uint32    `json:"usageLimit"`
+	Active     *bool      `json:"active"`
+}
+
+// ApplyDiscountRequest names the promotion to apply, either by coupon code or by id.
+type ApplyDiscountRequest struct {
+	Code        string `json:"code"`
+	PromotionId uint32 `json:"promotionId"`
+}
+
+func GetPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var promotions []*models.Promotion
+		if err := config.GetDB().Order("id asc").Find(&promotions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetPromotions got error"})
+			return
+		}
+		c.JSON(http.StatusOK, promotions)
+	}
+}
+
+func CreatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var promotion models.Promotion
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if promotion.Code != nil {
+			code := normalizeCouponCode(*promotion.Code)
+			promotion.Code = &code
+			if code == "" {
+				promotion.Code = nil
+			}
+		}
+		promotion.TimesUsed = 0
+		promotion.Active = true
+		promotion.CreatedAt = time.Now()
+		promotion.UpdatedAt = time.Now()
+
+		if err := validatePromotion(promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := config.GetDB().Create(&promotion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the promotion"})
+			return
+		}
+		c.JSON(http.StatusOK, promotion)
+	}
+}
+
+func UpdatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var promotion PromotionUpdate
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		promotionId := c.Param("promotion_id")
+		var existingPromotion models.Promotion
+		if err := config.GetDB().Where("id = ?", promotionId).First(&existingPromotion).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+
+		if promotion.Name != "" {
+			existingPromotion.Name = promotion.Name
+		}
+		if promotion.Value != 0 {
+			existingPromotion.Value = promotion.Value
+		}
+		if promotion.MinSpend != nil {
+			existingPromotion.MinSpend = *promotion.MinSpend
+		}
+		if promotion.StartsAt != nil {
+			existingPromotion.StartsAt = promotion.StartsAt
+		}
+		if promotion.EndsAt != nil {
+			existingPromotion.EndsAt = promotion.EndsAt
+		}
+		if promotion.UsageLimit != nil {
+			existingPromotion.UsageLimit = *promotion.UsageLimit
+		}
+		if promotion.Active != nil {
+			existingPromotion.Active = *promotion.Active
+		}
+		existingPromotion.UpdatedAt = time.Now()
+
+		if err := validatePromotion(existingPromotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// times_used is left out so redemptions happening meanwhile are not overwritten
+		if err := config.GetDB().Omit("times_used").Save(&existingPromotion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Promotion update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingPromotion)
+	}
+}
+
+// GetPromotionRedemptions lists every invoice a promotion was applied to along with the total given away.
+func GetPromotionRedemptions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotionId := c.Param("promotion_id")
+		var discounts []*models.InvoiceDiscount
+		if err := config.GetDB().Where("promotion_id = ?", promotionId).Order("created_at asc").Find(&discounts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the promotion redemptions"})
+			return
+		}
+		totalAmount := 0.0
+		for _, discount := range discounts {
+			totalAmount += discount.Amount
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"total_count":  len(discounts),
+			"total_amount": toFixed(totalAmount, 2),
+			"redemptions":  discounts,
+		})
+	}
+}
+
+// ApplyInvoiceDiscount applies a promotion or coupon code to an unpaid invoice.
+func ApplyInvoiceDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request ApplyDiscountRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invoiceId := c.Param("invoice_id")
+		var invoice models.Invoice
+		if err := config.GetDB().Where("id = ?", invoiceId).First(&invoice).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if invoice.PaymentStatus != models.Pending {
+			c.JSON(http.StatusConflict, gin.H{"error": ErrInvoiceNotPending.Error()})
+			return
+		}
+
+		var promotion models.Promotion
+		query := config.GetDB()
+		switch {
+		case request.Code != "":
+			query = query.Where("code = ?", normalizeCouponCode(request.Code))
+		case request.PromotionId != 0:
+			// promotions with a code are coupons and can only be redeemed with it
+			query = query.Where("id = ? AND code IS NULL", request.PromotionId)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "either a code or a promotionId has to be sent"})
+			return
+		}
+		if err := query.First(&promotion).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+			return
+		}
+		if !promotion.ValidAt(time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "this promotion is not valid right now"})
+			return
+		}
+
+		discount := models.InvoiceDiscount{
+			InvoiceId:   invoice.ID,
+			PromotionId: promotion.ID,
+			Name:        promotion.Name,
+			Kind:        promotion.Kind,
+			Value:       promotion.Value,
+			Scope:       promotion.Scope,
+			FoodId:      promotion.FoodId,
+			MinSpend:    promotion.MinSpend,
+			UserId:      currentUserId(c),
+			CreatedAt:   time.Now(),
+		}
+		if promotion.Code != nil {
+			discount.Code = *promotion.Code
+		}
+
+		// the invoice is locked while the discount is checked, applied and priced,
+		// so concurrent requests can't apply the same promotion twice
+		var invoiceView InvoiceViewFormat
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invoice.ID).First(&invoice).Error; err != nil {
+				return err
+			}
+			if invoice.PaymentStatus != models.Pending {
+				return ErrInvoiceNotPending
+			}
+			var applied int64
+			if err := tx.Model(&models.InvoiceDiscount{}).Where("invoice_id = ? AND promotion_id = ?", invoice.ID, promotion.ID).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				return ErrPromotionApplied
+			}
+			view, err := invoiceViewIn(tx, &invoice)
+			if err != nil {
+				return err
+			}
+			if view.Subtotal < promotion.MinSpend {
+				return fmt.Errorf("%w of %.2f", ErrMinSpendNotMet, promotion.MinSpend)
+			}
+
+			// counting the redemption in the same statement as checking the limit keeps concurrent redemptions from overshooting it
+			result := tx.Model(&models.Promotion{}).
+				Where("id = ? AND (usage_limit = 0 OR times_used < usage_limit)", promotion.ID).
+				Update("times_used", gorm.Expr("times_used + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrPromotionUsedUp
+			}
+			if err := tx.Create(&discount).Error; err != nil {
+				return err
+			}
+
+			// record what the discount came to for reporting
+			invoiceView, err = invoiceViewIn(tx, &invoice)
+			if err != nil {
+				return err
+			}
+			for _, line := range invoiceView.Discounts {
+				if line.Id == discount.ID {
+					discount.Amount = line.Amount
+				}
+			}
+			return tx.Model(&discount).Update("amount", discount.Amount).Error
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrPromotionUsedUp), errors.Is(err, ErrPromotionApplied), errors.Is(err, ErrMinSpendNotMet), errors.Is(err, ErrInvoiceNotPending):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Discount was not applied"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, invoiceView)
+	}
+}
+
+// RemoveInvoiceDiscount takes a discount off an unpaid invoice and gives the redemption back to the promotion.
+func RemoveInvoiceDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invoiceId := c.Param("invoice_id")
+		var invoice models.Invoice
+		if err := config.GetDB().Where("id = ?", invoiceId).First(&invoice).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		if invoice.PaymentStatus != models.Pending {
+			c.JSON(http.StatusConflict, gin.H{"error": "discounts can only be removed from unpaid invoices"})
+			return
+		}
+
+		discountId := c.Param("discount_id")
+		var discount models.InvoiceDiscount
+		if err := config.GetDB().Where("id = ? AND invoice_id = ?", discountId, invoice.ID).First(&discount).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Discount not found"})
+			return
+		}
+
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&discount).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Promotion{}).
+				Where("id = ? AND times_used > 0", discount.PromotionId).
+				Update("times_used", gorm.Expr("times_used - 1")).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Discount was not removed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Discount removed successfully"})
+	}
+}
+
+func validatePromotion(promotion models.Promotion) error {
+	if err := validate.Struct(promotion); err != nil {
+		return fmt.Errorf("Promotion invalidated : %v", err.Error())
+	}
+	if promotion.Kind == models.PercentageDiscount && promotion.Value > 100 {
+		return fmt.Errorf("Promotion invalidated : a percentage discount can not be over 100")
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return fmt.Errorf("Promotion invalidated : endsAt has to be after startsAt")
+	}
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// computeDiscounts works out what each discount takes off the lines. Item discounts come off the
+// lines of their food first, order discounts then come off what is left and are spread over the
+// lines in proportion to their amounts. Discounts whose minimum spend is no longer met come to nothing.
+// It returns the discounted lines, on which taxes are charged, and one discount line per discount.
+func computeDiscounts(lines []invoiceLine, subtotal float64, discounts []models.InvoiceDiscount) ([]invoiceLine, []InvoiceDiscountLine) {
+	discounted := make([]invoiceLine, len(lines))
+	copy(discounted, lines)
+	discountLines := make([]InvoiceDiscountLine, len(discounts))
+	for i, discount := range discounts {
+		discountLines[i] = InvoiceDiscountLine{Id: discount.ID, PromotionId: discount.PromotionId, Name: discount.Name, Code: discount.Code, Scope: discount.Scope}
+	}
+
+	for i, discount := range discounts {
+		if discount.Scope != models.ItemScope || subtotal < discount.MinSpend {
+			continue
+		}
+		for j := range discounted {
+			line := &discounted[j]
+			if discount.FoodId == nil || *discount.FoodId != line.FoodId {
+				continue
+			}
+			amount := discount.Value * line.Quantity
+			if discount.Kind == models.PercentageDiscount {
+				amount = line.Amount * discount.Value / 100
+			}
+			amount = toFixed(min(amount, line.Amount), 2)
+			line.Amount -= amount
+			discountLines[i].Amount += amount
+		}
+	}
+
+	for i, discount := range discounts {
+		if discount.Scope != models.OrderScope || subtotal < discount.MinSpend {
+			continue
+		}
+		remaining := 0.0
+		for _, line := range discounted {
+			remaining += line.Amount
+		}
+		if remaining <= 0 {
+			continue
+		}
+		amount := discount.Value
+		if discount.Kind == models.PercentageDiscount {
+			amount = remaining * discount.Value / 100
+		}
+		amount = toFixed(min(amount, remaining), 2)
+		for j := range discounted {
+			discounted[j].Amount -= amount * discounted[j].Amount / remaining
+		}
+		discountLines[i].Amount += amount
+	}
+
+	for i := range discountLines {
+		discountLines[i].Amount = toFixed(discountLines[i].Amount, 2)
+	}
+	return discounted, discountLines
+}
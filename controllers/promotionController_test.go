@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"math"
+	"testing"
+)
+
+func TestComputeDiscounts(t *testing.T) {
+	burger := uint32(1)
+	lines := []invoiceLine{{FoodId: 1, Quantity: 2, Amount: 10}, {FoodId: 2, Quantity: 1, Amount: 20}}
+	itemFixed := func(value float64) models.InvoiceDiscount {
+		return models.InvoiceDiscount{Kind: models.FixedDiscount, Value: value, Scope: models.ItemScope, FoodId: &burger}
+	}
+	orderFixed := func(value float64) models.InvoiceDiscount {
+		return models.InvoiceDiscount{Kind: models.FixedDiscount, Value: value, Scope: models.OrderScope}
+	}
+
+	cases := []struct {
+		name          string
+		discounts     []models.InvoiceDiscount
+		wantDiscounts []float64
+		wantLines     []float64
+	}{
+		{
+			name:          "fixed item discount comes off every unit",
+			discounts:     []models.InvoiceDiscount{itemFixed(3)},
+			wantDiscounts: []float64{6},
+			wantLines:     []float64{4, 20},
+		},
+		{
+			name:          "item discount is capped at the line",
+			discounts:     []models.InvoiceDiscount{itemFixed(8)},
+			wantDiscounts: []float64{10},
+			wantLines:     []float64{0, 20},
+		},
+		{
+			name:          "percentage item discount",
+			discounts:     []models.InvoiceDiscount{{Kind: models.PercentageDiscount, Value: 50, Scope: models.ItemScope, FoodId: &burger}},
+			wantDiscounts: []float64{5},
+			wantLines:     []float64{5, 20},
+		},
+		{
+			name:          "order discount is spread in proportion to the lines",
+			discounts:     []models.InvoiceDiscount{orderFixed(6)},
+			wantDiscounts: []float64{6},
+			wantLines:     []float64{8, 16},
+		},
+		{
+			name:          "order discount is capped at the subtotal",
+			discounts:     []models.InvoiceDiscount{orderFixed(50)},
+			wantDiscounts: []float64{30},
+			wantLines:     []float64{0, 0},
+		},
+		{
+			name:          "order discount comes off what item discounts left",
+			discounts:     []models.InvoiceDiscount{{Kind: models.PercentageDiscount, Value: 10, Scope: models.OrderScope}, itemFixed(2)},
+			wantDiscounts: []float64{2.6, 4},
+			wantLines:     []float64{5.4, 18},
+		},
+		{
+			name:          "a second order discount is capped at what the first left",
+			discounts:     []models.InvoiceDiscount{orderFixed(25), orderFixed(10)},
+			wantDiscounts: []float64{25, 5},
+			wantLines:     []float64{0, 0},
+		},
+		{
+			name:          "minimum spend not met",
+			discounts:     []models.InvoiceDiscount{{Kind: models.FixedDiscount, Value: 5, Scope: models.OrderScope, MinSpend: 50}},
+			wantDiscounts: []float64{0},
+			wantLines:     []float64{10, 20},
+		},
+	}
+	for _, tc := range cases {
+		gotLines, gotDiscounts := computeDiscounts(lines, 30, tc.discounts)
+		for i, want := range tc.wantDiscounts {
+			if gotDiscounts[i].Amount != want {
+				t.Errorf("%s: discount %d took off %v, want %v", tc.name, i, gotDiscounts[i].Amount, want)
+			}
+		}
+		for i, want := range tc.wantLines {
+			if math.Abs(gotLines[i].Amount-want) > 0.005 {
+				t.Errorf("%s: line %d came to %v, want %v", tc.name, i, gotLines[i].Amount, want)
+			}
+		}
+		if lines[0].Amount != 10 || lines[1].Amount != 20 {
+			t.Fatalf("%s: the lines passed in were changed", tc.name)
+		}
+	}
+}
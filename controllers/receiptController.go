@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/KhetwalDevesh/restaurant-management/receipts"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// GetInvoicePDF renders the invoice as a printable PDF receipt.
+func GetInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invoiceId := c.Param("invoice_id")
+		var invoice models.Invoice
+		if err := config.GetDB().Where("id = ?", invoiceId).First(&invoice).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+			return
+		}
+		invoiceView, err := invoiceViewOf(&invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing invoice item"})
+			return
+		}
+
+		var pdf bytes.Buffer
+		if err := receipts.WritePDF(&pdf, receiptOf(invoiceView, invoice.CreatedAt)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render the receipt"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%d.pdf\"", invoice.ID))
+		c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+	}
+}
+
+// orderTypeLabel names takeaway and delivery orders on receipts and tickets, dine-in orders go by their table.
+func orderTypeLabel(orderType models.OrderType) string {
+	switch orderType {
+	case models.Takeaway:
+		return "Takeaway"
+	case models.Delivery:
+		return "Delivery"
+	default:
+		return ""
+	}
+}
+
+// receiptOf turns an invoice view into what gets printed on its receipt.
+func receiptOf(invoiceView InvoiceViewFormat, issuedAt time.Time) receipts.Receipt {
+	receipt := receipts.Receipt{
+		Restaurant:    receipts.RestaurantFromEnv(),
+		InvoiceId:     invoiceView.Id,
+		OrderId:       invoiceView.OrderId,
+		IssuedAt:      issuedAt,
+		Subtotal:      invoiceView.Subtotal,
+		Total:         invoiceView.TotalAmount,
+		AmountPaid:    invoiceView.AmountPaid,
+		Tips:          invoiceView.TipTotal,
+		Balance:       invoiceView.Balance,
+		PaymentStatus: string(invoiceView.PaymentStatus),
+	}
+	if tableNumber, ok := invoiceView.TableNumber.(uint); ok {
+		receipt.TableNumber = tableNumber
+	}
+	if invoiceView.OrderType != models.DineIn {
+		receipt.OrderType = orderTypeLabel(invoiceView.OrderType)
+		receipt.Customer = invoiceView.CustomerName
+		receipt.Address = invoiceView.Address
+	}
+	if invoiceView.DeliveryFee != 0 {
+		receipt.Charges = append(receipt.Charges, receipts.Adjustment{Name: "Delivery fee", Amount: invoiceView.DeliveryFee})
+	}
+	if invoiceView.SplitCount > 0 {
+		receipt.Part = fmt.Sprintf("%d of %d", invoiceView.SplitIndex, invoiceView.SplitCount)
+	}
+
+	for _, line := range invoiceView.Lines {
+		receiptLine := receipts.Line{
+			Name:      line.Name,
+			Quantity:  fmt.Sprintf("%g", toFixed(float64(line.Quantity)*line.Share, 2)),
+			UnitPrice: line.UnitPrice,
+			Amount:    line.Amount,
+		}
+		if line.VariantName != "" {
+			receiptLine.Details = append(receiptLine.Details, line.VariantName)
+		}
+		for _, modifier := range line.Modifiers {
+			receiptLine.UnitPrice += modifier.PriceDelta
+			if modifier.PriceDelta != 0 {
+				receiptLine.Details = append(receiptLine.Details, fmt.Sprintf("%s (%+.2f)", modifier.Name, modifier.PriceDelta))
+			} else {
+				receiptLine.Details = append(receiptLine.Details, modifier.Name)
+			}
+		}
+		if line.Seat != 0 {
+			receiptLine.Details = append(receiptLine.Details, fmt.Sprintf("seat %d", line.Seat))
+		}
+		if line.Notes != "" {
+			receiptLine.Details = append(receiptLine.Details, line.Notes)
+		}
+		receipt.Lines = append(receipt.Lines, receiptLine)
+	}
+
+	for _, discount := range invoiceView.Discounts {
+		name := discount.Name
+		if discount.Code != "" {
+			name += " (" + discount.Code + ")"
+		}
+		receipt.Discounts = append(receipt.Discounts, receipts.Adjustment{Name: name, Amount: discount.Amount})
+	}
+	for _, tax := range invoiceView.Taxes {
+		receipt.Taxes = append(receipt.Taxes, receipts.Adjustment{
+			Name:     fmt.Sprintf("%s %g%%", tax.Name, tax.Rate),
+			Amount:   tax.Amount,
+			Included: tax.Inclusive,
+		})
+	}
+	for _, payment := range invoiceView.Payments {
+		if payment.Failed() {
+			continue
+		}
+		receiptPayment := receipts.Payment{Method: string(payment.Method), Amount: payment.Amount, Tip: payment.Tip, Note: payment.Reference}
+		if payment.Kind == models.PaymentRefund {
+			receiptPayment.Amount, receiptPayment.Tip = -payment.Amount, -payment.Tip
+			receiptPayment.Note = "refund: " + payment.Reason
+		}
+		receipt.Payments = append(receipt.Payments, receiptPayment)
+	}
+	return receipt
+}
@@ -0,0 +1,411 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultReservationLength is how long a table is booked for when no end time is given.
+	defaultReservationLength = 2 * time.Hour
+	// reservationSlot is the interval availability is searched in.
+	reservationSlot = 30 * time.Minute
+)
+
+var (
+	// ErrNoTableAvailable is returned when no table big enough is free for the whole reservation.
+	ErrNoTableAvailable = errors.New("no table is available for this party at this time")
+	// ErrTableTooSmall is returned when a reservation asks for a table with fewer seats than the party.
+	ErrTableTooSmall = errors.New("the table does not seat this party")
+	// ErrTableBooked is returned when a reservation asks for a table that is booked for part of the time.
+	ErrTableBooked = errors.New("the table is already booked at this time")
+	// ErrReservationClosed is returned when changing a reservation that was cancelled, completed or missed.
+	ErrReservationClosed = errors.New("the reservation can no longer be changed")
+	// ErrIllegalReservationTransition is returned when a reservation is asked to move to a status its lifecycle does not allow.
+	ErrIllegalReservationTransition = errors.New("illegal reservation status transition")
+	// ErrInvalidReservation is returned when a changed reservation doesn't validate or starts in the past.
+	ErrInvalidReservation = errors.New("Reservation invalidated")
+)
+
+// ReservationUpdate is the body accepted when changing a reservation, pointers tell unset fields from zero values.
+// The table is reassigned when the party or time changes and the current table no longer fits, unless TableId is sent.
+// Cancelled and missed reservations only take a Status that books them again, which checks the table and time anew.
+type ReservationUpdate struct {
+	GuestName string                    `json:"guestName"`
+	PartySize uint                      `json:"partySize"`
+	Phone     *string                   `json:"phone"`
+	Email     *string                   `json:"email"`
+	Notes     *string                   `json:"notes"`
+	StartsAt  *time.Time                `json:"startsAt"`
+	EndsAt    *time.Time                `json:"endsAt"`
+	TableId   uint                      `json:"tableId"`
+	Status    *models.ReservationStatus `json:"status"`
+}
+
+// AvailabilitySlot is a time a party can be booked at and the tables free for it.
+type AvailabilitySlot struct {
+	StartsAt time.Time
+	EndsAt   time.Time
+	TableIds []uint
+}
+
+// GetReservations lists reservations, those on a date when given date=YYYY-MM-DD and with a status when given status.
+func GetReservations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := config.GetDB().Preload("Table").Order("starts_at asc, id asc")
+		if date := c.Query("date"); date != "" {
+			day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "date has to be YYYY-MM-DD"})
+				return
+			}
+			query = query.Where("starts_at >= ? AND starts_at < ?", day, day.AddDate(0, 0, 1))
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		var reservations []*models.Reservation
+		if err := query.Find(&reservations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the reservations"})
+			return
+		}
+		c.JSON(http.StatusOK, reservations)
+	}
+}
+
+func GetReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservationId := c.Param("reservation_id")
+		var reservation models.Reservation
+		if err := config.GetDB().Preload("Table").Where("id = ?", reservationId).First(&reservation).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reservation not found"})
+			return
+		}
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// CreateReservation books a table, the smallest free table that seats the party unless a tableId is sent.
+func CreateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var reservation models.Reservation
+		if err := c.BindJSON(&reservation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if reservation.EndsAt.IsZero() {
+			reservation.EndsAt = reservation.StartsAt.Add(defaultReservationLength)
+		}
+		reservation.Status = models.ReservationBooked
+		reservation.UserId = currentUserId(c)
+		reservation.CreatedAt = time.Now()
+		reservation.UpdatedAt = time.Now()
+
+		if err := validate.Struct(reservation); err != nil {
+			msg := fmt.Sprintf("Reservation invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if reservation.StartsAt.Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reservation invalidated : startsAt is in the past"})
+			return
+		}
+
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := assignTable(tx, &reservation, reservation.TableId); err != nil {
+				return err
+			}
+			return tx.Omit(clause.Associations).Create(&reservation).Error
+		})
+		if err != nil {
+			c.JSON(reservationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+func UpdateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update ReservationUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reservationId := c.Param("reservation_id")
+		var reservation models.Reservation
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", reservationId).First(&reservation).Error; err != nil {
+				return err
+			}
+			return updateReservation(tx, &reservation, update)
+		})
+		if err != nil {
+			c.JSON(reservationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// updateReservation applies the update to a reservation locked by the caller and saves it. The table is
+// checked again whenever the party or time changes or the reservation is booked again, and the start
+// has to be in the future for booked reservations whose time changed or that are booked again.
+func updateReservation(tx *gorm.DB, reservation *models.Reservation, update ReservationUpdate) error {
+	previous := reservation.Status
+	if update.Status != nil && *update.Status != previous {
+		if !previous.CanTransitionTo(*update.Status) {
+			return fmt.Errorf("%w: %s to %s", ErrIllegalReservationTransition, previous, *update.Status)
+		}
+		reservation.Status = *update.Status
+	}
+	reactivated := !previous.HoldsTable() && reservation.Status.HoldsTable()
+	if !previous.HoldsTable() && !reactivated {
+		return ErrReservationClosed
+	}
+
+	rebook := reactivated || (update.TableId != 0 && update.TableId != reservation.TableId)
+	retimed := false
+	if update.GuestName != "" {
+		reservation.GuestName = update.GuestName
+	}
+	if update.PartySize != 0 && update.PartySize != reservation.PartySize {
+		reservation.PartySize = update.PartySize
+		rebook = true
+	}
+	if update.Phone != nil {
+		reservation.Phone = *update.Phone
+	}
+	if update.Email != nil {
+		reservation.Email = *update.Email
+	}
+	if update.Notes != nil {
+		reservation.Notes = *update.Notes
+	}
+	if update.StartsAt != nil && !update.StartsAt.Equal(reservation.StartsAt) {
+		// moving the start keeps the length of the booking unless the end is moved too
+		length := reservation.EndsAt.Sub(reservation.StartsAt)
+		reservation.StartsAt = *update.StartsAt
+		reservation.EndsAt = update.StartsAt.Add(length)
+		rebook, retimed = true, true
+	}
+	if update.EndsAt != nil && !update.EndsAt.Equal(reservation.EndsAt) {
+		reservation.EndsAt = *update.EndsAt
+		rebook, retimed = true, true
+	}
+	reservation.UpdatedAt = time.Now()
+
+	if err := validate.Struct(reservation); err != nil {
+		return fmt.Errorf("%w : %v", ErrInvalidReservation, err)
+	}
+	if (retimed || reactivated) && reservation.Status == models.ReservationBooked && reservation.StartsAt.Before(time.Now()) {
+		return fmt.Errorf("%w : startsAt is in the past", ErrInvalidReservation)
+	}
+
+	if rebook && reservation.Status.HoldsTable() {
+		tableId := update.TableId
+		if tableId == 0 {
+			tableId = reservation.TableId
+		}
+		err := assignTable(tx, reservation, tableId)
+		if err != nil && update.TableId == 0 && (errors.Is(err, ErrTableTooSmall) || errors.Is(err, ErrTableBooked)) {
+			// the party's table no longer fits, any other table will do
+			err = assignTable(tx, reservation, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Omit(clause.Associations).Save(reservation).Error
+}
+
+// CancelReservation cancels a booking and frees its table.
+func CancelReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reservationId := c.Param("reservation_id")
+		result := config.GetDB().Model(&models.Reservation{}).
+			Where("id = ? AND status = ?", reservationId, models.ReservationBooked).
+			Updates(map[string]interface{}{"status": models.ReservationCancelled, "updated_at": time.Now()})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Reservation was not cancelled"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "only booked reservations can be cancelled"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled successfully"})
+	}
+}
+
+// GetAvailability lists the times on date=YYYY-MM-DD a party of partySize can be booked at, with the tables
+// free for each. Bookings last duration minutes, two hours by default, and have to end by closing time.
+func GetAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		day, err := time.ParseInLocation("2006-01-02", c.Query("date"), time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date has to be YYYY-MM-DD"})
+			return
+		}
+		partySize, err := strconv.ParseUint(c.Query("partySize"), 10, 32)
+		if err != nil || partySize == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "partySize has to be a positive number"})
+			return
+		}
+		length := defaultReservationLength
+		if duration := c.Query("duration"); duration != "" {
+			minutes, err := strconv.ParseUint(duration, 10, 32)
+			if err != nil || minutes == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "duration has to be a positive number of minutes"})
+				return
+			}
+			length = time.Duration(minutes) * time.Minute
+		}
+		opens, closes, err := openingHours(day)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var tables []models.Table
+		if err := config.GetDB().Where("number_of_guests >= ?", partySize).Order("number_of_guests asc, table_number asc").Find(&tables).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the tables"})
+			return
+		}
+		var bookings []models.Reservation
+		err = config.GetDB().
+			Where("status IN ? AND starts_at < ? AND ends_at > ?", []models.ReservationStatus{models.ReservationBooked, models.ReservationSeated}, closes, opens).
+			Find(&bookings).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the reservations"})
+			return
+		}
+
+		slots := []AvailabilitySlot{}
+		for start := opens; !start.Add(length).After(closes); start = start.Add(reservationSlot) {
+			if start.Before(time.Now()) {
+				continue
+			}
+			end := start.Add(length)
+			slot := AvailabilitySlot{StartsAt: start, EndsAt: end}
+			for _, table := range tables {
+				free := true
+				for _, booking := range bookings {
+					if booking.TableId == table.ID && booking.StartsAt.Before(end) && booking.EndsAt.After(start) {
+						free = false
+						break
+					}
+				}
+				if free {
+					slot.TableIds = append(slot.TableIds, table.ID)
+				}
+			}
+			if len(slot.TableIds) > 0 {
+				slots = append(slots, slot)
+			}
+		}
+		c.JSON(http.StatusOK, slots)
+	}
+}
+
+// assignTable books tableId for the reservation, or the smallest free table seating the party when tableId is 0.
+// The tables are locked so two bookings can't take the same table at once.
+func assignTable(tx *gorm.DB, reservation *models.Reservation, tableId uint) error {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if tableId != 0 {
+		var table models.Table
+		if err := query.Where("id = ?", tableId).First(&table).Error; err != nil {
+			return err
+		}
+		if table.NumberOfGuests < reservation.PartySize {
+			return fmt.Errorf("%w: table %d seats %d", ErrTableTooSmall, table.TableNumber, table.NumberOfGuests)
+		}
+		free, err := tableFree(tx, table.ID, reservation.StartsAt, reservation.EndsAt, reservation.ID)
+		if err != nil {
+			return err
+		}
+		if !free {
+			return fmt.Errorf("%w: table %d", ErrTableBooked, table.TableNumber)
+		}
+		reservation.TableId = table.ID
+		return nil
+	}
+
+	var tables []models.Table
+	if err := query.Where("number_of_guests >= ?", reservation.PartySize).Order("number_of_guests asc, table_number asc").Find(&tables).Error; err != nil {
+		return err
+	}
+	for _, table := range tables {
+		free, err := tableFree(tx, table.ID, reservation.StartsAt, reservation.EndsAt, reservation.ID)
+		if err != nil {
+			return err
+		}
+		if free {
+			reservation.TableId = table.ID
+			return nil
+		}
+	}
+	return ErrNoTableAvailable
+}
+
+// tableFree reports whether no other reservation holds the table for any part of the time.
+func tableFree(tx *gorm.DB, tableId uint, startsAt, endsAt time.Time, reservationId uint32) (bool, error) {
+	var overlapping int64
+	err := tx.Model(&models.Reservation{}).
+		Where("table_id = ? AND id <> ? AND status IN ?", tableId, reservationId, []models.ReservationStatus{models.ReservationBooked, models.ReservationSeated}).
+		Where("starts_at < ? AND ends_at > ?", endsAt, startsAt).
+		Count(&overlapping).Error
+	return overlapping == 0, err
+}
+
+// openingHours returns when the restaurant opens and closes on the day, from RESTAURANT_OPENS and
+// RESTAURANT_CLOSES as "15:04", 11:00 to 23:00 by default. Closing times before opening are the next day.
+func openingHours(day time.Time) (time.Time, time.Time, error) {
+	at := func(env, fallback string) (time.Time, error) {
+		value := os.Getenv(env)
+		if value == "" {
+			value = fallback
+		}
+		clock, err := time.Parse("15:04", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s has to be HH:MM: %v", env, err)
+		}
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location()), nil
+	}
+	opens, err := at("RESTAURANT_OPENS", "11:00")
+	if err != nil {
+		return opens, opens, err
+	}
+	closes, err := at("RESTAURANT_CLOSES", "23:00")
+	if err != nil {
+		return opens, closes, err
+	}
+	if !closes.After(opens) {
+		closes = closes.AddDate(0, 0, 1)
+	}
+	return opens, closes, nil
+}
+
+func reservationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoTableAvailable), errors.Is(err, ErrTableBooked), errors.Is(err, ErrReservationClosed),
+		errors.Is(err, ErrIllegalReservationTransition):
+		return http.StatusConflict
+	case errors.Is(err, ErrTableTooSmall), errors.Is(err, ErrInvalidReservation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
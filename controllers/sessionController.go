@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"errors"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// StaffSessions are the active sessions of a user.
+type StaffSessions struct {
+	UserId   uint32           `json:"userId"`
+	Name     string           `json:"name"`
+	Email    string           `json:"email"`
+	Role     models.Role      `json:"role"`
+	Sessions []models.Session `json:"sessions"`
+}
+
+// GetSessions lists the active sessions of the authenticated user, marking the one the request was made with.
+func GetSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var sessions []models.Session
+		if err := activeSessions(config.GetDB()).Where("user_id = ?", currentUserId(c)).Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the sessions"})
+			return
+		}
+		familyId := c.GetString("familyId")
+		for i := range sessions {
+			sessions[i].Current = familyId != "" && sessions[i].FamilyId == familyId
+		}
+		c.JSON(http.StatusOK, sessions)
+	}
+}
+
+// EndSession logs the authenticated user out of one of their sessions, e.g. on a tablet they left logged in.
+func EndSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session models.Session
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id = ? AND user_id = ?", c.Param("session_id"), currentUserId(c)).First(&session).Error; err != nil {
+				return err
+			}
+			return revokeTokenFamilies(tx, session.UserId, currentUserId(c), []string{session.FamilyId})
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while ending the session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "the session was ended"})
+	}
+}
+
+// GetStaffSessions lists the active sessions of every user, or only those of ?userId.
+func GetStaffSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := activeSessions(config.GetDB())
+		if userId := c.Query("userId"); userId != "" {
+			query = query.Where("user_id = ?", userId)
+		}
+		var sessions []models.Session
+		if err := query.Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the sessions"})
+			return
+		}
+
+		userIds := make([]uint32, 0, len(sessions))
+		byUser := make(map[uint32]*StaffSessions)
+		for _, session := range sessions {
+			if byUser[session.UserId] == nil {
+				byUser[session.UserId] = &StaffSessions{UserId: session.UserId, Sessions: []models.Session{}}
+				userIds = append(userIds, session.UserId)
+			}
+			byUser[session.UserId].Sessions = append(byUser[session.UserId].Sessions, session)
+		}
+		var users []models.User
+		if len(userIds) > 0 {
+			if err := config.GetDB().Where("id IN ?", userIds).Order("name asc").Find(&users).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the sessions"})
+				return
+			}
+		}
+
+		staffSessions := make([]StaffSessions, 0, len(users))
+		for _, user := range users {
+			entry := byUser[user.ID]
+			entry.Name = user.Name
+			entry.Email = user.Email
+			entry.Role = user.Role
+			staffSessions = append(staffSessions, *entry)
+		}
+		c.JSON(http.StatusOK, staffSessions)
+	}
+}
+
+// activeSessions selects the sessions that have not been logged out of and whose refresh token has not expired,
+// most recently seen first.
+func activeSessions(tx *gorm.DB) *gorm.DB {
+	return tx.Where("ended_at IS NULL AND expires_at > ?", time.Now()).Order("last_seen_at desc")
+}
+
+// startSession logs the user in on the device the request came from, starting a new refresh token family.
+func startSession(c *gin.Context, user models.User, deviceName string) (token string, refreshToken string, err error) {
+	err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+		var familyId string
+		token, refreshToken, familyId, err = issueTokens(tx, user, "")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		session := models.Session{
+			FamilyId:   familyId,
+			UserId:     user.ID,
+			DeviceName: deviceName,
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(helper.RefreshTokenLifetime),
+		}
+		return tx.Create(&session).Error
+	})
+	return token, refreshToken, err
+}
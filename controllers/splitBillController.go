@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrOrderNotSettled is returned when an order is closed before every invoice for it has been paid.
+	ErrOrderNotSettled = errors.New("order is not settled, every invoice for it has to be paid first")
+	// ErrNoItemsToBill is returned when billing an order that has no items.
+	ErrNoItemsToBill = errors.New("this order has no items to bill")
+	// ErrInvalidSplit is returned when a split doesn't fit the order's items.
+	ErrInvalidSplit = errors.New("Split invalidated")
+)
+
+// SplitBillRequest describes how to split an order's bill. Even splits make Parts invoices,
+// or one per entry in Splits when Parts is not sent. Seat splits make one invoice per seat,
+// items shared by the table being divided evenly between seats. Items splits make one invoice
+// per entry in Splits, with every order item assigned to exactly one of them.
+type SplitBillRequest struct {
+	Mode   models.SplitMode `json:"mode" validate:"oneof=even seat items"`
+	Parts  uint32           `json:"parts"`
+	Splits []SplitBillPart  `json:"splits" validate:"dive"`
+}
+
+// SplitBillPart is one of the invoices a bill is split into.
+type SplitBillPart struct {
+	PaymentMethod models.PaymentMethod `json:"paymentMethod" validate:"omitempty,oneof=card cash"`
+	Seat          uint32               `json:"seat"`
+	OrderItemIds  []uint32             `json:"orderItemIds"`
+}
+
+// splitInvoice is an invoice about to be created by a split along with the order item shares it covers.
+type splitInvoice struct {
+	invoice models.Invoice
+	shares  map[uint32]float64
+}
+
+// SplitOrderBill splits the bill of an order that has not been billed yet across several invoices.
+func SplitOrderBill() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request SplitBillRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Split invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		orderId := c.Param("order_id")
+		var splits []splitInvoice
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			// the order is locked so it is billed once and no item is added while it is split
+			order, err := lockOpenOrder(tx, orderId)
+			if err != nil {
+				return err
+			}
+			var orderItems []models.OrderItem
+			if err := tx.Where("order_id = ?", order.ID).Order("id asc").Find(&orderItems).Error; err != nil {
+				return err
+			}
+			if len(orderItems) == 0 {
+				return ErrNoItemsToBill
+			}
+
+			switch request.Mode {
+			case models.EvenSplit:
+				splits, err = splitEvenly(request, orderItems)
+			case models.SeatSplit:
+				splits, err = splitBySeat(request, orderItems)
+			case models.ItemsSplit:
+				splits, err = splitByItems(request, orderItems)
+			}
+			if err != nil {
+				return fmt.Errorf("%w : %v", ErrInvalidSplit, err)
+			}
+
+			for i := range splits {
+				invoice := &splits[i].invoice
+				invoice.OrderID = order.ID
+				invoice.SplitMode = request.Mode
+				invoice.SplitIndex = uint32(i + 1)
+				invoice.SplitCount = uint32(len(splits))
+				invoice.PaymentStatus = models.Pending
+				invoice.PaymentDueDate = time.Now().AddDate(0, 0, 1)
+				invoice.CreatedAt = time.Now()
+				invoice.UpdatedAt = time.Now()
+				if invoice.PaymentMethod == "" {
+					invoice.PaymentMethod = models.Cash
+				}
+				if err := tx.Create(invoice).Error; err != nil {
+					return err
+				}
+				invoiceItems := make([]models.InvoiceItem, 0, len(splits[i].shares))
+				for orderItemId, share := range splits[i].shares {
+					invoiceItems = append(invoiceItems, models.InvoiceItem{InvoiceId: invoice.ID, OrderItemId: orderItemId, Share: share})
+				}
+				if len(invoiceItems) > 0 {
+					if err := tx.Create(&invoiceItems).Error; err != nil {
+						return err
+					}
+				}
+				if err := snapshotTaxRates(tx, invoice.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if status := billingErrorStatus(err); status != http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invoices were not created"})
+			return
+		}
+
+		invoices := make([]models.Invoice, 0, len(splits))
+		for _, split := range splits {
+			invoices = append(invoices, split.invoice)
+		}
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+// GetOrderInvoices lists the invoices of an order and whether the order is settled.
+func GetOrderInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderId := c.Param("order_id")
+		var invoices []*models.Invoice
+		if err := config.GetDB().Where("order_id = ?", orderId).Order("split_index asc, id asc").Find(&invoices).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the order invoices"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"invoices": invoices,
+			"settled":  invoicesSettled(invoices),
+		})
+	}
+}
+
+// orderSettled reports whether the order has been billed and every one of its invoices paid.
+func orderSettled(tx *gorm.DB, orderId uint32) (bool, error) {
+	var invoices []*models.Invoice
+	if err := tx.Where("order_id = ?", orderId).Find(&invoices).Error; err != nil {
+		return false, err
+	}
+	return invoicesSettled(invoices), nil
+}
+
+func invoicesSettled(invoices []*models.Invoice) bool {
+	if len(invoices) == 0 {
+		return false
+	}
+	for _, invoice := range invoices {
+		if !invoice.PaymentStatus.Settled() {
+			return false
+		}
+	}
+	return true
+}
+
+func splitEvenly(request SplitBillRequest, orderItems []models.OrderItem) ([]splitInvoice, error) {
+	parts := int(request.Parts)
+	if parts == 0 {
+		parts = len(request.Splits)
+	}
+	if parts < 2 {
+		return nil, fmt.Errorf("an even split needs at least 2 parts")
+	}
+	if len(request.Splits) != 0 && len(request.Splits) != parts {
+		return nil, fmt.Errorf("%d splits were sent for %d parts", len(request.Splits), parts)
+	}
+
+	splits := make([]splitInvoice, parts)
+	for i := range splits {
+		splits[i].shares = make(map[uint32]float64, len(orderItems))
+		for _, item := range orderItems {
+			splits[i].shares[item.ID] = 1 / float64(parts)
+		}
+		if len(request.Splits) != 0 {
+			splits[i].invoice.PaymentMethod = request.Splits[i].PaymentMethod
+		}
+	}
+	return splits, nil
+}
+
+func splitBySeat(request SplitBillRequest, orderItems []models.OrderItem) ([]splitInvoice, error) {
+	seatSet := make(map[uint32]bool)
+	for _, item := range orderItems {
+		if item.Seat != 0 {
+			seatSet[item.Seat] = true
+		}
+	}
+	if len(seatSet) == 0 {
+		return nil, fmt.Errorf("none of the order items has a seat")
+	}
+	seats := make([]uint32, 0, len(seatSet))
+	for seat := range seatSet {
+		seats = append(seats, seat)
+	}
+	sort.Slice(seats, func(i, j int) bool { return seats[i] < seats[j] })
+
+	paymentMethods := make(map[uint32]models.PaymentMethod)
+	for _, part := range request.Splits {
+		if !seatSet[part.Seat] {
+			return nil, fmt.Errorf("no order item is for seat %d", part.Seat)
+		}
+		paymentMethods[part.Seat] = part.PaymentMethod
+	}
+
+	splits := make([]splitInvoice, len(seats))
+	for i, seat := range seats {
+		splits[i].invoice.PaymentMethod = paymentMethods[seat]
+		splits[i].shares = make(map[uint32]float64)
+		for _, item := range orderItems {
+			switch item.Seat {
+			case seat:
+				splits[i].shares[item.ID] = 1
+			case 0:
+				splits[i].shares[item.ID] = 1 / float64(len(seats))
+			}
+		}
+	}
+	return splits, nil
+}
+
+func splitByItems(request SplitBillRequest, orderItems []models.OrderItem) ([]splitInvoice, error) {
+	if len(request.Splits) < 2 {
+		return nil, fmt.Errorf("an items split needs at least 2 splits")
+	}
+	unassigned := make(map[uint32]bool, len(orderItems))
+	for _, item := range orderItems {
+		unassigned[item.ID] = true
+	}
+
+	splits := make([]splitInvoice, len(request.Splits))
+	for i, part := range request.Splits {
+		if len(part.OrderItemIds) == 0 {
+			return nil, fmt.Errorf("split %d has no order items", i+1)
+		}
+		splits[i].invoice.PaymentMethod = part.PaymentMethod
+		splits[i].shares = make(map[uint32]float64, len(part.OrderItemIds))
+		for _, orderItemId := range part.OrderItemIds {
+			if !unassigned[orderItemId] {
+				return nil, fmt.Errorf("order item %d is not part of this order or is assigned twice", orderItemId)
+			}
+			delete(unassigned, orderItemId)
+			splits[i].shares[orderItemId] = 1
+		}
+	}
+	if len(unassigned) > 0 {
+		return nil, fmt.Errorf("%d order item(s) are not assigned to any split", len(unassigned))
+	}
+	return splits, nil
+}
+
+// billingErrorStatus maps the errors of billing an order, on top of those of locking it for billing.
+func billingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNoItemsToBill), errors.Is(err, ErrInvalidSplit):
+		return http.StatusBadRequest
+	default:
+		return orderMoveErrorStatus(err)
+	}
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"reflect"
+	"testing"
+)
+
+// splitResult is what a split comes to, without the invoice fields the handler fills in.
+type splitResult struct {
+	paymentMethod models.PaymentMethod
+	shares        map[uint32]float64
+}
+
+func splitResults(splits []splitInvoice) []splitResult {
+	results := make([]splitResult, len(splits))
+	for i, split := range splits {
+		results[i] = splitResult{paymentMethod: split.invoice.PaymentMethod, shares: split.shares}
+	}
+	return results
+}
+
+func orderItemsOnSeats(seats ...uint32) []models.OrderItem {
+	orderItems := make([]models.OrderItem, len(seats))
+	for i, seat := range seats {
+		orderItems[i].ID = uint32(i + 1)
+		orderItems[i].Seat = seat
+	}
+	return orderItems
+}
+
+func TestSplitEvenly(t *testing.T) {
+	orderItems := orderItemsOnSeats(0, 0)
+	cases := []struct {
+		name    string
+		request SplitBillRequest
+		want    []splitResult
+		wantErr bool
+	}{
+		{
+			name:    "parts",
+			request: SplitBillRequest{Parts: 2},
+			want:    []splitResult{{shares: map[uint32]float64{1: 0.5, 2: 0.5}}, {shares: map[uint32]float64{1: 0.5, 2: 0.5}}},
+		},
+		{
+			name:    "parts from the splits sent",
+			request: SplitBillRequest{Splits: []SplitBillPart{{PaymentMethod: models.Card}, {PaymentMethod: models.Cash}, {}}},
+			want: []splitResult{
+				{paymentMethod: models.Card, shares: map[uint32]float64{1: 1.0 / 3, 2: 1.0 / 3}},
+				{paymentMethod: models.Cash, shares: map[uint32]float64{1: 1.0 / 3, 2: 1.0 / 3}},
+				{shares: map[uint32]float64{1: 1.0 / 3, 2: 1.0 / 3}},
+			},
+		},
+		{name: "a single part", request: SplitBillRequest{Parts: 1}, wantErr: true},
+		{name: "no parts", request: SplitBillRequest{}, wantErr: true},
+		{name: "splits that don't match the parts", request: SplitBillRequest{Parts: 3, Splits: []SplitBillPart{{}, {}}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		splits, err := splitEvenly(tc.request, orderItems)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := splitResults(splits); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSplitBySeat(t *testing.T) {
+	cases := []struct {
+		name       string
+		request    SplitBillRequest
+		orderItems []models.OrderItem
+		want       []splitResult
+		wantErr    bool
+	}{
+		{
+			name:       "one invoice per seat in seat order, sharing the items with no seat",
+			orderItems: orderItemsOnSeats(2, 1, 0, 2),
+			want: []splitResult{
+				{shares: map[uint32]float64{2: 1, 3: 0.5}},
+				{shares: map[uint32]float64{1: 1, 3: 0.5, 4: 1}},
+			},
+		},
+		{
+			name:       "payment methods by seat",
+			request:    SplitBillRequest{Splits: []SplitBillPart{{Seat: 2, PaymentMethod: models.Cash}}},
+			orderItems: orderItemsOnSeats(1, 2),
+			want: []splitResult{
+				{shares: map[uint32]float64{1: 1}},
+				{paymentMethod: models.Cash, shares: map[uint32]float64{2: 1}},
+			},
+		},
+		{name: "no seats", orderItems: orderItemsOnSeats(0, 0), wantErr: true},
+		{
+			name:       "a payment method for a seat with no items",
+			request:    SplitBillRequest{Splits: []SplitBillPart{{Seat: 3, PaymentMethod: models.Card}}},
+			orderItems: orderItemsOnSeats(1, 2),
+			wantErr:    true,
+		},
+	}
+	for _, tc := range cases {
+		splits, err := splitBySeat(tc.request, tc.orderItems)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := splitResults(splits); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSplitByItems(t *testing.T) {
+	orderItems := orderItemsOnSeats(0, 0, 0)
+	cases := []struct {
+		name    string
+		request SplitBillRequest
+		want    []splitResult
+		wantErr bool
+	}{
+		{
+			name: "every item on one split",
+			request: SplitBillRequest{Splits: []SplitBillPart{
+				{PaymentMethod: models.Card, OrderItemIds: []uint32{1, 3}},
+				{OrderItemIds: []uint32{2}},
+			}},
+			want: []splitResult{
+				{paymentMethod: models.Card, shares: map[uint32]float64{1: 1, 3: 1}},
+				{shares: map[uint32]float64{2: 1}},
+			},
+		},
+		{name: "a single split", request: SplitBillRequest{Splits: []SplitBillPart{{OrderItemIds: []uint32{1, 2, 3}}}}, wantErr: true},
+		{name: "a split with no items", request: SplitBillRequest{Splits: []SplitBillPart{{OrderItemIds: []uint32{1, 2, 3}}, {}}}, wantErr: true},
+		{name: "an item on two splits", request: SplitBillRequest{Splits: []SplitBillPart{{OrderItemIds: []uint32{1, 2}}, {OrderItemIds: []uint32{2, 3}}}}, wantErr: true},
+		{name: "an item of another order", request: SplitBillRequest{Splits: []SplitBillPart{{OrderItemIds: []uint32{1, 2}}, {OrderItemIds: []uint32{3, 4}}}}, wantErr: true},
+		{name: "an item left out", request: SplitBillRequest{Splits: []SplitBillPart{{OrderItemIds: []uint32{1}}, {OrderItemIds: []uint32{2}}}}, wantErr: true},
+	}
+	for _, tc := range cases {
+		splits, err := splitByItems(tc.request, orderItems)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: got no error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := splitResults(splits); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -10,16 +10,32 @@ import (
 	"time"
 )
 
+// TableUpdate is the body accepted when updating a table, pointers tell unset fields from zero values.
+// A SectionId of 0 takes the table out of its section.
+type TableUpdate struct {
+	NumberOfGuests uint     `json:"numberOfGuests"`
+	TableNumber    uint     `json:"tableNumber"`
+	SectionId      *uint    `json:"sectionId"`
+	X              *float64 `json:"x"`
+	Y              *float64 `json:"y"`
+}
+
 func GetTables() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		var allTables []*models.Table
-		err := config.GetDB().Model(models.Table{}).Find(&allTables)
+		var allTables []models.Table
+		err := config.GetDB().Model(models.Table{}).Order("table_number asc, id asc").Find(&allTables).Error
 		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetTables got error"})
+			return
+		}
+		tableViews, err := tableViewsOf(config.GetDB(), allTables)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetTables got error"})
+			return
 		}
-		c.JSON(http.StatusOK, allTables)
+		c.JSON(http.StatusOK, tableViews)
 	}
 }
 
@@ -32,24 +48,26 @@ func GetTable() gin.HandlerFunc {
 		defer cancel()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the table item"})
+			return
 		}
-		c.JSON(http.StatusOK, table)
+		tableViews, err := tableViewsOf(config.GetDB(), []models.Table{table})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the table item"})
+			return
+		}
+		c.JSON(http.StatusOK, tableViews[0])
 	}
 }
 
 func CreateTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var _, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		isUserAdmin, _ := c.Get("isAdmin")
-		if isUserAdmin == false {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "You need to be an admin to create a table"})
-			return
-		}
 		var table *models.Table
 		if err := c.BindJSON(&table); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		table.ManualStatus = ""
 		table.CreatedAt = time.Now()
 		table.UpdatedAt = time.Now()
 
@@ -60,6 +78,11 @@ func CreateTable() gin.HandlerFunc {
 			return
 		}
 
+		if err := checkFloorSection(table.SectionId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		err := config.GetDB().Model(models.Table{}).Create(&table).Error
 		if err != nil {
 			msg := fmt.Sprintf("Error creating the table")
@@ -73,7 +96,7 @@ func CreateTable() gin.HandlerFunc {
 
 func UpdateTable() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var table models.Table
+		var table TableUpdate
 
 		tableID := c.Param("table_id")
 
@@ -85,22 +108,35 @@ func UpdateTable() gin.HandlerFunc {
 		var updateObj = make(map[string]interface{})
 
 		if table.NumberOfGuests != 0 {
-			updateObj["numberOfGuests"] = table.NumberOfGuests
+			updateObj["number_of_guests"] = table.NumberOfGuests
 		}
 
 		if table.TableNumber != 0 {
-			updateObj["tableNumber"] = table.TableNumber
+			updateObj["table_number"] = table.TableNumber
 		}
 
-		table.UpdatedAt = time.Now()
+		if table.SectionId != nil {
+			if *table.SectionId == 0 {
+				updateObj["section_id"] = nil
+			} else {
+				if err := checkFloorSection(table.SectionId); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				updateObj["section_id"] = *table.SectionId
+			}
+		}
 
-		// validate table data before storing it in db
-		if err := validate.Struct(table); err != nil {
-			msg := fmt.Sprintf("Table data invalidated : %v", err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
+		if table.X != nil {
+			updateObj["x"] = *table.X
 		}
 
+		if table.Y != nil {
+			updateObj["y"] = *table.Y
+		}
+
+		updateObj["updated_at"] = time.Now()
+
 		// Update fields in the database
 		if err := config.GetDB().Model(&models.Table{}).Where("id = ?", tableID).Updates(updateObj).Error; err != nil {
 			msg := "Table item update failed"
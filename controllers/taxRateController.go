@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// InvoiceTaxLine is the amount of one tax charged on an invoice.
+type InvoiceTaxLine struct {
+	Name          string
+	Rate          float64
+	Inclusive     bool
+	TaxableAmount float64
+	Amount        float64
+}
+
+// TaxRateUpdate is the body accepted when updating a tax rate, pointers tell unset fields from zero values.
+type TaxRateUpdate struct {
+	Name      string  `json:"name"`
+	Rate      float64 `json:"rate"`
+	Inclusive *bool   `json:"inclusive"`
+	Category  *string `json:"category"`
+	FoodId    *uint32 `json:"foodId"`
+	Active    *bool   `json:"active"`
+}
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var taxRates []*models.TaxRate
+		if err := config.GetDB().Order("id asc").Find(&taxRates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "GetTaxRates got error"})
+			return
+		}
+		c.JSON(http.StatusOK, taxRates)
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var taxRate models.TaxRate
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		taxRate.Active = true
+		taxRate.CreatedAt = time.Now()
+		taxRate.UpdatedAt = time.Now()
+
+		// validate tax rate data before storing it in db
+		if err := validate.Struct(taxRate); err != nil {
+			msg := fmt.Sprintf("Tax rate invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Create(&taxRate).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating the tax rate"})
+			return
+		}
+		c.JSON(http.StatusOK, taxRate)
+	}
+}
+
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var taxRate TaxRateUpdate
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		taxRateId := c.Param("tax_rate_id")
+		var existingTaxRate models.TaxRate
+		if err := config.GetDB().Where("id = ?", taxRateId).First(&existingTaxRate).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tax rate not found"})
+			return
+		}
+
+		if taxRate.Name != "" {
+			existingTaxRate.Name = taxRate.Name
+		}
+		if taxRate.Rate != 0 {
+			existingTaxRate.Rate = taxRate.Rate
+		}
+		if taxRate.Inclusive != nil {
+			existingTaxRate.Inclusive = *taxRate.Inclusive
+		}
+		if taxRate.Category != nil {
+			existingTaxRate.Category = *taxRate.Category
+		}
+		if taxRate.FoodId != nil {
+			existingTaxRate.FoodId = taxRate.FoodId
+		}
+		if taxRate.Active != nil {
+			existingTaxRate.Active = *taxRate.Active
+		}
+		existingTaxRate.UpdatedAt = time.Now()
+
+		// validate tax rate data before storing it in db
+		if err := validate.Struct(existingTaxRate); err != nil {
+			msg := fmt.Sprintf("Tax rate invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := config.GetDB().Save(&existingTaxRate).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Tax rate update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, existingTaxRate)
+	}
+}
+
+// snapshotTaxRates copies the active tax rates onto the invoice.
+func snapshotTaxRates(tx *gorm.DB, invoiceId uint32) error {
+	var taxRates []models.TaxRate
+	if err := tx.Where("active = ?", true).Order("id asc").Find(&taxRates).Error; err != nil {
+		return err
+	}
+	if len(taxRates) == 0 {
+		return nil
+	}
+	snapshots := make([]models.InvoiceTaxRate, 0, len(taxRates))
+	for _, taxRate := range taxRates {
+		snapshots = append(snapshots, models.InvoiceTaxRate{
+			InvoiceId: invoiceId,
+			TaxRateId: taxRate.ID,
+			Name:      taxRate.Name,
+			Rate:      taxRate.Rate,
+			Inclusive: taxRate.Inclusive,
+			Category:  taxRate.Category,
+			FoodId:    taxRate.FoodId,
+			CreatedAt: time.Now(),
+		})
+	}
+	return tx.Create(&snapshots).Error
+}
+
+// computeTaxes works out every tax charged on the lines. Stacked taxes are each charged on the
+// line amount net of inclusive taxes. It returns one tax line per rate that applied to anything
+// and the total of the exclusive taxes, which is what gets added on top of the lines.
+func computeTaxes(lines []invoiceLine, rates []models.InvoiceTaxRate) ([]InvoiceTaxLine, float64) {
+	taxLines := make([]InvoiceTaxLine, len(rates))
+	applied := make([]bool, len(rates))
+	for i, rate := range rates {
+		taxLines[i] = InvoiceTaxLine{Name: rate.Name, Rate: rate.Rate, Inclusive: rate.Inclusive}
+	}
+
+	for _, line := range lines {
+		inclusiveRate := 0.0
+		for _, rate := range rates {
+			if rate.Inclusive && rate.AppliesTo(line.FoodId, line.Category) {
+				inclusiveRate += rate.Rate
+			}
+		}
+		net := line.Amount / (1 + inclusiveRate/100)
+		for i, rate := range rates {
+			if !rate.AppliesTo(line.FoodId, line.Category) {
+				continue
+			}
+			applied[i] = true
+			taxLines[i].TaxableAmount += net
+			taxLines[i].Amount += net * rate.Rate / 100
+		}
+	}
+
+	result := []InvoiceTaxLine{}
+	exclusiveTotal := 0.0
+	for i, taxLine := range taxLines {
+		if !applied[i] {
+			continue
+		}
+		taxLine.TaxableAmount = toFixed(taxLine.TaxableAmount, 2)
+		taxLine.Amount = toFixed(taxLine.Amount, 2)
+		if !taxLine.Inclusive {
+			exclusiveTotal += taxLine.Amount
+		}
+		result = append(result, taxLine)
+	}
+	return result, toFixed(exclusiveTotal, 2)
+}
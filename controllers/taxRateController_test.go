@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"reflect"
+	"testing"
+)
+
+func TestComputeTaxes(t *testing.T) {
+	vat := models.InvoiceTaxRate{Name: "VAT", Rate: 20, Inclusive: true}
+	service := models.InvoiceTaxRate{Name: "Service", Rate: 10}
+	drinks := models.InvoiceTaxRate{Name: "Drinks", Rate: 5, Category: "drinks"}
+
+	cases := []struct {
+		name          string
+		lines         []invoiceLine
+		rates         []models.InvoiceTaxRate
+		wantLines     []InvoiceTaxLine
+		wantExclusive float64
+	}{
+		{
+			name:      "no rates",
+			lines:     []invoiceLine{{FoodId: 1, Amount: 100}},
+			wantLines: []InvoiceTaxLine{},
+		},
+		{
+			name:          "exclusive tax is added on top",
+			lines:         []invoiceLine{{FoodId: 1, Amount: 100}},
+			rates:         []models.InvoiceTaxRate{service},
+			wantLines:     []InvoiceTaxLine{{Name: "Service", Rate: 10, TaxableAmount: 100, Amount: 10}},
+			wantExclusive: 10,
+		},
+		{
+			name:      "inclusive tax is taken out of the price",
+			lines:     []invoiceLine{{FoodId: 1, Amount: 120}},
+			rates:     []models.InvoiceTaxRate{vat},
+			wantLines: []InvoiceTaxLine{{Name: "VAT", Rate: 20, Inclusive: true, TaxableAmount: 100, Amount: 20}},
+		},
+		{
+			name:  "stacked exclusive tax is charged net of inclusive tax",
+			lines: []invoiceLine{{FoodId: 1, Amount: 120}},
+			rates: []models.InvoiceTaxRate{vat, service},
+			wantLines: []InvoiceTaxLine{
+				{Name: "VAT", Rate: 20, Inclusive: true, TaxableAmount: 100, Amount: 20},
+				{Name: "Service", Rate: 10, TaxableAmount: 100, Amount: 10},
+			},
+			wantExclusive: 10,
+		},
+		{
+			name:          "rates that apply to nothing are left out",
+			lines:         []invoiceLine{{FoodId: 1, Category: "mains", Amount: 100}, {FoodId: 2, Category: "Drinks", Amount: 40}},
+			rates:         []models.InvoiceTaxRate{drinks, {Name: "Dessert", Rate: 5, Category: "desserts"}},
+			wantLines:     []InvoiceTaxLine{{Name: "Drinks", Rate: 5, TaxableAmount: 40, Amount: 2}},
+			wantExclusive: 2,
+		},
+		{
+			name:          "amounts are rounded to the cent",
+			lines:         []invoiceLine{{FoodId: 1, Amount: 9.99}, {FoodId: 2, Amount: 0.05}},
+			rates:         []models.InvoiceTaxRate{{Name: "Sales", Rate: 8.25}},
+			wantLines:     []InvoiceTaxLine{{Name: "Sales", Rate: 8.25, TaxableAmount: 10.04, Amount: 0.83}},
+			wantExclusive: 0.83,
+		},
+	}
+	for _, tc := range cases {
+		gotLines, gotExclusive := computeTaxes(tc.lines, tc.rates)
+		if !reflect.DeepEqual(gotLines, tc.wantLines) {
+			t.Errorf("%s: got tax lines %+v, want %+v", tc.name, gotLines, tc.wantLines)
+		}
+		if gotExclusive != tc.wantExclusive {
+			t.Errorf("%s: got exclusive total %v, want %v", tc.name, gotExclusive, tc.wantExclusive)
+		}
+	}
+}
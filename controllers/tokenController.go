@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for refresh tokens that are unknown, expired or revoked.
+	ErrRefreshTokenInvalid = errors.New("the refresh token is invalid, please log in again")
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated is used again.
+	ErrRefreshTokenReused = errors.New("the refresh token was already used, every session started from the same login has been signed out")
+)
+
+// RefreshRequest carries the refresh token to exchange for a new token pair.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// TokenPair is an access token along with the refresh token to get the next one with.
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The refresh token can only be used once,
+// using it again revokes every token rotated from the same login.
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request RefreshRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			msg := fmt.Sprintf("Refresh request invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		claims, err := helper.ValidateRefreshToken(request.RefreshToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": ErrRefreshTokenInvalid.Error()})
+			return
+		}
+
+		var tokens TokenPair
+		reused := false
+		err = config.GetDB().Transaction(func(tx *gorm.DB) error {
+			var refreshToken models.RefreshToken
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_id = ?", claims.Id).First(&refreshToken).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			if err != nil {
+				return err
+			}
+			if refreshToken.RevokedAt != nil || refreshToken.UserId != claims.UserId || refreshToken.FamilyId != claims.FamilyId {
+				return ErrRefreshTokenInvalid
+			}
+			if refreshToken.RotatedAt != nil {
+				// the revocation has to be committed, so the reuse is reported once the transaction is over
+				reused = true
+				return revokeTokenFamilies(tx, refreshToken.UserId, 0, []string{refreshToken.FamilyId})
+			}
+
+			var user models.User
+			if err := tx.Where("id = ?", refreshToken.UserId).First(&user).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrRefreshTokenInvalid
+				}
+				return err
+			}
+			now := time.Now()
+			if err := tx.Model(&refreshToken).Update("rotated_at", now).Error; err != nil {
+				return err
+			}
+			tokens.Token, tokens.RefreshToken, _, err = issueTokens(tx, user, refreshToken.FamilyId)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.Session{}).Where("family_id = ?", refreshToken.FamilyId).Updates(map[string]interface{}{
+				"last_seen_at": now,
+				"expires_at":   now.Add(helper.RefreshTokenLifetime),
+			}).Error
+		})
+		if err == nil && reused {
+			err = ErrRefreshTokenReused
+		}
+		if err != nil {
+			if errors.Is(err, ErrRefreshTokenInvalid) || errors.Is(err, ErrRefreshTokenReused) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while refreshing the token"})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// issueTokens signs a new token pair for the user and records its refresh token as the newest of the family,
+// starting a new family when familyId is empty. The family of the tokens is returned along with them.
+func issueTokens(tx *gorm.DB, user models.User, familyId string) (token string, refreshToken string, tokenFamilyId string, err error) {
+	refreshClaims, err := helper.NewRefreshClaims(user.ID, familyId)
+	if err != nil {
+		return "", "", "", err
+	}
+	token, refreshToken, err = helper.GenerateAllTokens(user.Email, user.Name, user.ID, user.Role, refreshClaims)
+	if err != nil {
+		return "", "", "", err
+	}
+	record := models.RefreshToken{
+		TokenId:   refreshClaims.Id,
+		FamilyId:  refreshClaims.FamilyId,
+		UserId:    user.ID,
+		ExpiresAt: time.Unix(refreshClaims.ExpiresAt, 0),
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", "", "", err
+	}
+	return token, refreshToken, refreshClaims.FamilyId, nil
+}
+
+// Logout signs out of the session the request was made with, revoking its refresh token family.
+func Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		familyId := c.GetString("familyId")
+		if familyId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "this token was issued before sessions could be signed out of, it expires on its own"})
+			return
+		}
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			return revokeTokenFamilies(tx, currentUserId(c), currentUserId(c), []string{familyId})
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while logging out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
+
+// LogoutAll signs the authenticated user out of every session.
+func LogoutAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revoked, err := revokeUserTokens(currentUserId(c), currentUserId(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while logging out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out of every session", "sessions": revoked})
+	}
+}
+
+// LogoutUser signs another user out of every session, e.g. when they leave the restaurant.
+func LogoutUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := config.GetDB().Where("id = ?", c.Param("user_id")).First(&user).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		revoked, err := revokeUserTokens(user.ID, currentUserId(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while logging the user out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "the user was logged out of every session", "sessions": revoked})
+	}
+}
+
+// revokeUserTokens revokes every refresh token family of the user that is still in use,
+// returning how many were revoked.
+func revokeUserTokens(userId uint32, revokedBy uint32) (int, error) {
+	var revoked int
+	err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		revoked, err = revokeUserTokensIn(tx, userId, revokedBy)
+		return err
+	})
+	return revoked, err
+}
+
+// revokeUserTokensIn is revokeUserTokens as part of the caller's transaction.
+func revokeUserTokensIn(tx *gorm.DB, userId uint32, revokedBy uint32) (int, error) {
+	var familyIds []string
+	err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Distinct().Pluck("family_id", &familyIds).Error
+	if err != nil {
+		return 0, err
+	}
+	return len(familyIds), revokeTokenFamilies(tx, userId, revokedBy, familyIds)
+}
+
+// revokeTokenFamilies revokes the refresh tokens of the families, ends their sessions and records
+// the revocations, which the authentication middleware checks access tokens against.
+func revokeTokenFamilies(tx *gorm.DB, userId uint32, revokedBy uint32, familyIds []string) error {
+	if len(familyIds) == 0 {
+		return nil
+	}
+	now := time.Now()
+	err := tx.Model(&models.RefreshToken{}).
+		Where("family_id IN ? AND revoked_at IS NULL", familyIds).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&models.Session{}).
+		Where("family_id IN ? AND ended_at IS NULL", familyIds).
+		Update("ended_at", now).Error
+	if err != nil {
+		return err
+	}
+	revocations := make([]models.TokenRevocation, 0, len(familyIds))
+	for _, familyId := range familyIds {
+		revocations = append(revocations, models.TokenRevocation{
+			FamilyId:  familyId,
+			UserId:    userId,
+			RevokedBy: revokedBy,
+			RevokedAt: now,
+			// the newest access token of the family was issued at the latest now
+			ExpiresAt: now.Add(helper.AccessTokenLifetime),
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revocations).Error
+}
@@ -4,11 +4,11 @@ import (
 	"errors"
 	"fmt"
 	config "github.com/KhetwalDevesh/restaurant-management/database"
-	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
 	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
 	"net/http"
 	"strconv"
@@ -72,86 +72,142 @@ func GetUser() gin.HandlerFunc {
 	}
 }
 
+// SignUpRequest is what a new member of staff signs up with. Their role is given by an owner afterwards.
+type SignUpRequest struct {
+	Name       string `json:"name" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required,min=8,max=72"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+}
+
 func SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var user models.User
+		var signUp SignUpRequest
 
 		// Convert the JSON data coming from Postman to something that Golang understands
-		if err := c.BindJSON(&user); err != nil {
+		if err := c.BindJSON(&signUp); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validate.Struct(signUp); err != nil {
+			msg := fmt.Sprintf("User data invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
 
 		// Check if the email has already been used by another user
 		var existingUser models.User
-		if err := config.GetDB().Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
+		if err := config.GetDB().Where("email = ?", signUp.Email).First(&existingUser).Error; err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "this email already exists"})
 			return
 		}
 
 		// Hash password
-		password := HashPassword(user.Password)
-		user.Password = password
-		//user.IsAdmin
+		user := models.User{Name: signUp.Name, Email: signUp.Email}
+		user.Password = HashPassword(signUp.Password)
+
 		// Create some extra details for the user object - created_at, updated_at, ID
 		user.CreatedAt = time.Now()
 		user.UpdatedAt = time.Now()
 
-		// If all is ok, then insert this new user into the users table
-		if err := config.GetDB().Create(&user).Error; err != nil {
+		// The first user to sign up owns the restaurant, everyone after starts as a waiter
+		// until an owner gives them another role. Signups take turns on the users table so
+		// two first signups can't both become owner.
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+			var userCount int64
+			if err := tx.Model(&models.User{}).Count(&userCount).Error; err != nil {
+				return err
+			}
+			user.Role = models.RoleWaiter
+			if userCount == 0 {
+				user.Role = models.RoleOwner
+			}
+			user.IsAdmin = user.Role.IsAdmin()
+
+			// validate user data before storing it in db
+			if err := validate.Struct(user); err != nil {
+				return err
+			}
+			// If all is ok, then insert this new user into the users table
+			return tx.Create(&user).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while creating user"})
 			return
 		}
 
-		// Generate token and refresh token
-		token, refreshToken, _ := helper.GenerateAllTokens(user.Email, user.Name, user.ID, user.IsAdmin)
-		user.Token = token
-		user.RefreshToken = refreshToken
-
-		// validate user data before storing it in db
-		if err := validate.Struct(user); err != nil {
-			msg := fmt.Sprintf("User data invalidated : %v", err.Error())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		// The account is usable even if the email can't be sent, the user can ask for it again
+		if err := sendUserToken(c.Request.Context(), user, models.VerifyEmail); err != nil {
+			log.Printf("verification email to user %d was not sent: %v", user.ID, err)
+		}
+		if emailVerificationRequired() {
+			c.JSON(http.StatusOK, gin.H{"message": user})
 			return
 		}
 
-		if err := config.GetDB().Save(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while generating and assigning token to user"})
+		// Generate token and refresh token, starting a session on the device the user signed up from
+		token, refreshToken, err := startSession(c, user, signUp.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while generating tokens"})
 			return
 		}
+		user.Token = token
+		user.RefreshToken = refreshToken
 		c.JSON(http.StatusOK, gin.H{"message": user})
 	}
 }
 
+// LoginRequest carries the credentials to log in with. DeviceName labels the session on the user's session list.
+type LoginRequest struct {
+	Email      string `json:"email" validate:"required"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"deviceName" validate:"max=100"`
+}
+
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var user models.User
+		var login LoginRequest
 		var foundUser models.User
 
 		// Convert the login data from Postman which is in JSON to Golang readable format
-		if err := c.BindJSON(&user); err != nil {
+		if err := c.BindJSON(&login); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if err := validate.Struct(login); err != nil {
+			msg := fmt.Sprintf("Login invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
 
 		// Find a user with that email and see if that user even exists
-		if err := config.GetDB().Where("email = ?", user.Email).First(&foundUser).Error; err != nil {
+		if err := config.GetDB().Where("email = ?", login.Email).First(&foundUser).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found, login seems to be incorrect"})
 			return
 		}
 
 		// Then you will verify the password
-		passwordIsValid, msg := VerifyPassword(user.Password, foundUser.Password)
+		passwordIsValid, msg := VerifyPassword(login.Password, foundUser.Password)
 		if !passwordIsValid {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		// If all goes well, then you'll generate tokens
-		token, refreshToken, _ := helper.GenerateAllTokens(foundUser.Email, foundUser.Name, foundUser.ID, foundUser.IsAdmin)
+		if err := checkEmailVerified(foundUser); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// If all goes well, then you'll start a session on this device and generate its tokens
+		token, refreshToken, err := startSession(c, foundUser, login.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while generating tokens"})
+			return
+		}
 
-		// Update tokens - token and refresh token using GORM
-		helper.UpdateAllTokens(token, refreshToken, foundUser.ID)
 		// Return status OK
 		foundUser.Token = token
 		foundUser.RefreshToken = refreshToken
@@ -159,6 +215,94 @@ func Login() gin.HandlerFunc {
 	}
 }
 
+// ErrLastOwner is returned when the role of the only owner left is changed, leaving nobody to manage users.
+var ErrLastOwner = errors.New("the restaurant needs at least one owner, make someone else an owner first")
+
+// UserRoleUpdate is the role to give a user.
+type UserRoleUpdate struct {
+	Role models.Role `json:"role" validate:"oneof=owner manager cashier waiter chef host"`
+}
+
+// RoleView is a role along with what it is allowed to do.
+type RoleView struct {
+	Role        models.Role         `json:"role"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// GetRoles lists the roles a user can be given and their permissions.
+func GetRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles := make([]RoleView, 0, len(models.Roles))
+		for _, role := range models.Roles {
+			roles = append(roles, RoleView{Role: role, Permissions: role.Permissions()})
+		}
+		c.JSON(http.StatusOK, roles)
+	}
+}
+
+// SetUserRole gives a user another role. It applies to the tokens the user gets from their next login.
+func SetUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update UserRoleUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(update); err != nil {
+			msg := fmt.Sprintf("Role invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		userId := c.Param("user_id")
+		var user models.User
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userId).First(&user).Error; err != nil {
+				return err
+			}
+			if user.Role == models.RoleOwner && update.Role != models.RoleOwner {
+				// lock every owner so two owners can not demote each other at the same time
+				var owners []models.User
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("role = ?", models.RoleOwner).Find(&owners).Error; err != nil {
+					return err
+				}
+				if len(owners) <= 1 {
+					return ErrLastOwner
+				}
+			}
+			if user.Role == update.Role {
+				return nil
+			}
+			user.Role = update.Role
+			user.IsAdmin = update.Role.IsAdmin()
+			user.UpdatedAt = time.Now()
+			err := tx.Model(&user).Updates(map[string]interface{}{
+				"role":       user.Role,
+				"is_admin":   user.IsAdmin,
+				"updated_at": user.UpdatedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+			// tokens carry the role, the user signs in again to pick up the new one
+			_, err = revokeUserTokensIn(tx, user.ID, currentUserId(c))
+			return err
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			case errors.Is(err, ErrLastOwner):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while updating the user's role"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
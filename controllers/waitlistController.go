@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultTurnTime is how long a table is taken for when there is no history to go by.
+	defaultTurnTime = 60 * time.Minute
+	// minimumRemainingTurn is how long a table taken for longer than usual, or still being cleaned, is expected to stay unavailable.
+	minimumRemainingTurn = 5 * time.Minute
+	// turnTimeHistory is how far back closed orders are looked at for turn times.
+	turnTimeHistory = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrTableOccupied is returned when seating a party at a table that has an open order or is about to be booked.
+	ErrTableOccupied = errors.New("the table is occupied")
+	// ErrPartyNotWaiting is returned when changing or seating a party that was already seated or left.
+	ErrPartyNotWaiting = errors.New("the party is no longer waiting")
+)
+
+// WaitlistUpdate is the body accepted when updating a waitlist entry, pointers tell unset fields from zero values.
+type WaitlistUpdate struct {
+	GuestName string                 `json:"guestName"`
+	PartySize uint                   `json:"partySize"`
+	Phone     *string                `json:"phone"`
+	Notes     *string                `json:"notes"`
+	Status    *models.WaitlistStatus `json:"status" validate:"omitempty,oneof=waiting notified left"`
+}
+
+// SeatRequest picks the table a party is seated at, the smallest free table that fits when TableId is left out.
+type SeatRequest struct {
+	TableId uint `json:"tableId"`
+}
+
+// WaitlistEntryView is a waitlist entry with its place in the queue and how long it is expected to wait
+// in minutes, nil when no table can seat the party.
+type WaitlistEntryView struct {
+	models.WaitlistEntry
+	Position      int   `json:"position"`
+	EstimatedWait *uint `json:"estimatedWait"`
+}
+
+// GetWaitlist lists the parties still waiting, first come first, with their estimated waits.
+func GetWaitlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries, err := activeWaitlist(config.GetDB())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the waitlist"})
+			return
+		}
+		waits, err := estimateWaits(config.GetDB(), entries, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while estimating the waits"})
+			return
+		}
+		views := make([]WaitlistEntryView, 0, len(entries))
+		for i, entry := range entries {
+			views = append(views, WaitlistEntryView{WaitlistEntry: entry, Position: i + 1, EstimatedWait: waits[i]})
+		}
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+// GetWaitEstimate estimates how long a party of partySize joining the waitlist now would wait.
+func GetWaitEstimate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partySize, err := strconv.ParseUint(c.Query("partySize"), 10, 32)
+		if err != nil || partySize == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "partySize has to be a positive number"})
+			return
+		}
+		wait, err := estimateNewParty(config.GetDB(), uint(partySize))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while estimating the wait"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"partySize": partySize, "estimatedWait": wait})
+	}
+}
+
+// AddToWaitlist puts a walk-in party on the waitlist and quotes it a wait.
+func AddToWaitlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var entry models.WaitlistEntry
+		if err := c.BindJSON(&entry); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entry.Status = models.WaitlistWaiting
+		entry.TableId = nil
+		entry.OrderId = nil
+		entry.SeatedAt = nil
+		entry.UserId = currentUserId(c)
+		entry.CreatedAt = time.Now()
+		entry.UpdatedAt = time.Now()
+
+		if err := validate.Struct(entry); err != nil {
+			msg := fmt.Sprintf("Waitlist entry invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		wait, err := estimateNewParty(config.GetDB(), entry.PartySize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while estimating the wait"})
+			return
+		}
+		if wait == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "no table can seat this party"})
+			return
+		}
+		entry.QuotedWait = *wait
+
+		if err := config.GetDB().Create(&entry).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding the party to the waitlist"})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+func UpdateWaitlistEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var update WaitlistUpdate
+		if err := c.BindJSON(&update); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(update); err != nil {
+			msg := fmt.Sprintf("Waitlist entry invalidated : %v", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		now := time.Now()
+		changes := map[string]interface{}{"updated_at": now}
+		if update.GuestName != "" {
+			changes["guest_name"] = update.GuestName
+		}
+		if update.PartySize != 0 {
+			changes["party_size"] = update.PartySize
+		}
+		if update.Phone != nil {
+			changes["phone"] = *update.Phone
+		}
+		if update.Notes != nil {
+			changes["notes"] = *update.Notes
+		}
+		if update.Status != nil {
+			changes["status"] = *update.Status
+		}
+
+		entryId := c.Param("entry_id")
+		var entry models.WaitlistEntry
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			// only touch the columns sent, and only while the party is still waiting, so a seating in between is kept
+			result := tx.Model(&models.WaitlistEntry{}).
+				Where("id = ? AND status IN ?", entryId, []models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistNotified}).
+				Updates(changes)
+			if result.Error != nil {
+				return result.Error
+			}
+			if err := tx.Where("id = ?", entryId).First(&entry).Error; err != nil {
+				return err
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: the party is %s", ErrPartyNotWaiting, entry.Status)
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Waitlist entry not found"})
+				return
+			}
+			if errors.Is(err, ErrPartyNotWaiting) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Waitlist entry update failed"})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+// SeatWaitlistEntry seats a waiting party at a free table and opens an order for it.
+func SeatWaitlistEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request SeatRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		entryId := c.Param("entry_id")
+		var entry models.WaitlistEntry
+		var order models.Order
+		err := config.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id = ?", entryId).First(&entry).Error; err != nil {
+				return err
+			}
+			if entry.Status != models.WaitlistWaiting && entry.Status != models.WaitlistNotified {
+				return fmt.Errorf("%w: the party is %s", ErrPartyNotWaiting, entry.Status)
+			}
+			floor, err := loadFloor(tx, time.Now())
+			if err != nil {
+				return err
+			}
+			table, err := floor.freeTable(entry.PartySize, request.TableId)
+			if err != nil {
+				return err
+			}
+			// the floor is read without locks, lock the table and check nobody was sat at it meanwhile
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", table.ID).First(&models.Table{}).Error; err != nil {
+				return err
+			}
+			var openOrders int64
+			if err := tx.Model(&models.Order{}).Where("table_id = ? AND status NOT IN ?", table.ID, closedOrderStatuses).Count(&openOrders).Error; err != nil {
+				return err
+			}
+			if openOrders > 0 {
+				return fmt.Errorf("%w: table %d", ErrTableOccupied, table.TableNumber)
+			}
+
+			now := time.Now()
+			tableId := uint32(table.ID)
+			order = models.Order{
+				OrderDate: now,
+				Status:    models.OrderPlaced,
+				Type:      models.DineIn,
+				TableId:   &tableId,
+				UserId:    currentUserId(c),
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			if err := recordOrderHistory(tx, models.OrderHistory{
+				OrderId:  order.ID,
+				Action:   models.OrderHistoryStatus,
+				ToStatus: order.Status,
+				Note:     "seated from the waitlist",
+				UserId:   order.UserId,
+			}); err != nil {
+				return err
+			}
+
+			// only one host gets to seat the party
+			result := tx.Model(&models.WaitlistEntry{}).
+				Where("id = ? AND status IN ?", entry.ID, []models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistNotified}).
+				Updates(map[string]interface{}{"status": models.WaitlistSeated, "table_id": table.ID, "order_id": order.ID, "seated_at": now, "updated_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("%w: the party was already seated", ErrPartyNotWaiting)
+			}
+			entry.Status = models.WaitlistSeated
+			entry.TableId = &table.ID
+			entry.OrderId = &order.ID
+			entry.SeatedAt = &now
+			entry.UpdatedAt = now
+			return nil
+		})
+		if err != nil {
+			c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"entry": entry, "order": order})
+	}
+}
+
+func activeWaitlist(tx *gorm.DB) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+	err := tx.Where("status IN ?", []models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistNotified}).
+		Order("created_at asc, id asc").
+		Find(&entries).Error
+	return entries, err
+}
+
+// estimateNewParty estimates the wait in minutes of a party joining the back of the waitlist now.
+func estimateNewParty(tx *gorm.DB, partySize uint) (*uint, error) {
+	entries, err := activeWaitlist(tx)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, models.WaitlistEntry{PartySize: partySize})
+	waits, err := estimateWaits(tx, entries, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return waits[len(waits)-1], nil
+}
+
+// estimateWaits works out the wait in minutes of each party in the queue. Parties are seated in order
+// at whichever table that fits frees up first, tables freeing up once their open order has been going
+// for the table's usual turn time and staying taken for a turn once a party is seated.
+func estimateWaits(tx *gorm.DB, entries []models.WaitlistEntry, now time.Time) ([]*uint, error) {
+	floor, err := loadFloor(tx, now)
+	if err != nil {
+		return nil, err
+	}
+	waits := make([]*uint, len(entries))
+	for i, entry := range entries {
+		table, seatedAt := floor.nextTable(entry.PartySize)
+		if table == nil {
+			continue
+		}
+		table.freeAt = seatedAt.Add(table.turn)
+		minutes := uint(math.Ceil(seatedAt.Sub(now).Minutes()))
+		waits[i] = &minutes
+	}
+	return waits, nil
+}
+
+// floorTable is a table along with when it is expected to be free and how long parties usually stay at it.
+type floorTable struct {
+	models.Table
+	freeAt   time.Time
+	turn     time.Duration
+	bookings []models.Reservation
+}
+
+type floor struct {
+	now    time.Time
+	tables []*floorTable
+}
+
+// loadFloor works out when each table frees up from its open order and its turn time,
+// along with the reservations that will take it later on.
+func loadFloor(tx *gorm.DB, now time.Time) (*floor, error) {
+	var tables []models.Table
+	if err := tx.Order("number_of_guests asc, table_number asc").Find(&tables).Error; err != nil {
+		return nil, err
+	}
+	turns, fallback, err := turnTimes(tx, now)
+	if err != nil {
+		return nil, err
+	}
+	var openOrders []models.Order
+	err = tx.Where("status NOT IN ?", closedOrderStatuses).
+		Order("order_date asc").
+		Find(&openOrders).Error
+	if err != nil {
+		return nil, err
+	}
+	var bookings []models.Reservation
+	err = tx.Where("status IN ? AND ends_at > ?", []models.ReservationStatus{models.ReservationBooked, models.ReservationSeated}, now).
+		Order("starts_at asc").
+		Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	f := &floor{now: now}
+	byId := make(map[uint]*floorTable, len(tables))
+	for _, table := range tables {
+		turn, ok := turns[uint32(table.ID)]
+		if !ok {
+			turn = fallback
+		}
+		floorTable := &floorTable{Table: table, freeAt: now, turn: turn}
+		if table.ManualStatus != "" {
+			// still being turned over, it will be ready shortly
+			floorTable.freeAt = now.Add(minimumRemainingTurn)
+		}
+		f.tables = append(f.tables, floorTable)
+		byId[table.ID] = floorTable
+	}
+	for _, order := range openOrders {
+		if order.TableId == nil {
+			continue
+		}
+		table, ok := byId[uint(*order.TableId)]
+		if !ok {
+			continue
+		}
+		freeAt := order.OrderDate.Add(table.turn)
+		if earliest := now.Add(minimumRemainingTurn); freeAt.Before(earliest) {
+			freeAt = earliest
+		}
+		if freeAt.After(table.freeAt) {
+			table.freeAt = freeAt
+		}
+	}
+	for _, booking := range bookings {
+		if table, ok := byId[booking.TableId]; ok {
+			table.bookings = append(table.bookings, booking)
+		}
+	}
+	return f, nil
+}
+
+// seatableFrom returns the first time from at on which a party can be given the table for a whole turn
+// without running into one of its reservations.
+func (t *floorTable) seatableFrom(at time.Time) time.Time {
+	for _, booking := range t.bookings {
+		if booking.StartsAt.Before(at.Add(t.turn)) && booking.EndsAt.After(at) {
+			at = booking.EndsAt
+		}
+	}
+	return at
+}
+
+// nextTable returns the table that fits the party and can seat it soonest, the smallest one on a tie.
+func (f *floor) nextTable(partySize uint) (*floorTable, time.Time) {
+	var best *floorTable
+	var bestAt time.Time
+	for _, table := range f.tables {
+		if table.NumberOfGuests < partySize {
+			continue
+		}
+		at := table.seatableFrom(table.freeAt)
+		if best == nil || at.Before(bestAt) {
+			best, bestAt = table, at
+		}
+	}
+	return best, bestAt
+}
+
+// freeTable returns tableId, or the smallest table that fits the party, when it can be sat at right now.
+func (f *floor) freeTable(partySize uint, tableId uint) (*floorTable, error) {
+	for _, table := range f.tables {
+		if tableId != 0 && table.ID != tableId {
+			continue
+		}
+		if table.NumberOfGuests < partySize {
+			if tableId != 0 {
+				return nil, fmt.Errorf("%w: table %d seats %d", ErrTableTooSmall, table.TableNumber, table.NumberOfGuests)
+			}
+			continue
+		}
+		if table.freeAt.After(f.now) || table.seatableFrom(f.now).After(f.now) {
+			if tableId != 0 {
+				return nil, fmt.Errorf("%w: table %d", ErrTableOccupied, table.TableNumber)
+			}
+			continue
+		}
+		return table, nil
+	}
+	if tableId != 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return nil, ErrNoTableAvailable
+}
+
+// turnTimes returns how long parties have stayed at each table on average, from orders opened
+// until they were closed, along with the average over all tables or defaultTurnTime without any history.
+func turnTimes(tx *gorm.DB, now time.Time) (map[uint32]time.Duration, time.Duration, error) {
+	var rows []struct {
+		TableId uint32
+		Seconds float64
+		Orders  int64
+	}
+	err := tx.Table("orders o").
+		Select("o.table_id, AVG(EXTRACT(EPOCH FROM h.created_at - o.order_date)) AS seconds, COUNT(*) AS orders").
+		Joins("JOIN order_histories h ON h.order_id = o.id AND h.action = ? AND h.to_status = ?", models.OrderHistoryStatus, models.OrderClosed).
+		Where("o.table_id IS NOT NULL AND o.order_date > ?", now.Add(-turnTimeHistory)).
+		Group("o.table_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	turns := make(map[uint32]time.Duration, len(rows))
+	var totalSeconds float64
+	var totalOrders int64
+	for _, row := range rows {
+		turns[row.TableId] = time.Duration(row.Seconds) * time.Second
+		totalSeconds += row.Seconds * float64(row.Orders)
+		totalOrders += row.Orders
+	}
+	fallback := defaultTurnTime
+	if totalOrders > 0 {
+		fallback = time.Duration(totalSeconds/float64(totalOrders)) * time.Second
+	}
+	return turns, fallback, nil
+}
+
+func waitlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTableOccupied), errors.Is(err, ErrPartyNotWaiting):
+		return http.StatusConflict
+	default:
+		return reservationErrorStatus(err)
+	}
+}
@@ -31,18 +31,40 @@ func ConfigDB() {
 		fmt.Println("Error loading .env file")
 		return
 	}
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, user, password, dbname, port)
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
+	db, err = gorm.Open(postgres.Open(GetDSN()), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
 
 	if err != nil {
 		panic("Failed to connect to the database")
 	}
 	fmt.Println("Successfully connected to db", db)
 
-	err = db.AutoMigrate(&models.User{}, &models.OrderItem{}, &models.Order{}, &models.Food{}, &models.Menu{}, &models.Table{}, &models.Invoice{})
+	// accounts from before email verification can't be held to it, they are taken as verified once
+	verifyExistingUsers := !db.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt")
+	err = db.AutoMigrate(&models.User{}, &models.OrderItem{}, &models.Order{}, &models.Food{}, &models.Menu{}, &models.Table{}, &models.Invoice{}, &models.OrderHistory{}, &models.PricingRule{}, &models.FoodVariant{}, &models.ModifierGroup{}, &models.Modifier{}, &models.OrderItemModifier{}, &models.TaxRate{}, &models.InvoiceTaxRate{}, &models.Promotion{}, &models.InvoiceDiscount{}, &models.InvoiceItem{}, &models.Payment{}, &models.Printer{}, &models.KitchenStation{}, &models.StationRoute{}, &models.Reservation{}, &models.WaitlistEntry{}, &models.FloorSection{}, &models.GuestSubmission{}, &models.GuestSubmissionItem{}, &models.RefreshToken{}, &models.TokenRevocation{}, &models.Session{}, &models.UserToken{})
 	if err != nil {
 		panic("Failed to auto-migrate the model")
 	}
+	// order items from before courses were held back had all gone to the kitchen already
+	err = db.Exec("UPDATE order_items SET fired_at = created_at WHERE fired_at IS NULL AND course = 0").Error
+	if err != nil {
+		panic("Failed to fire the existing order items")
+	}
+	// users from before roles were either admins, who now own the restaurant, or waiters
+	err = db.Exec("UPDATE users SET role = CASE WHEN is_admin THEN 'owner' ELSE 'waiter' END WHERE role = ''").Error
+	if err != nil {
+		panic("Failed to give the existing users a role")
+	}
+	if verifyExistingUsers {
+		err = db.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error
+		if err != nil {
+			panic("Failed to verify the existing users")
+		}
+	}
+}
+
+// GetDSN returns the connection string used to reach the database
+func GetDSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable", host, user, password, dbname, port)
 }
 
 // GetDB returns an initialized instance of gorm.DB
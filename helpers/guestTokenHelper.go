@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	jwt "github.com/dgrijalva/jwt-go"
+	"os"
+	"time"
+)
+
+// guestAudience marks tokens that let guests order from a table, so they are never taken for staff tokens.
+const guestAudience = "guest"
+
+// GuestClaims are carried by the ordering token printed on a table's QR code. They do not expire,
+// a table's codes are revoked by bumping its GuestTokenVersion instead.
+type GuestClaims struct {
+	TableId uint
+	Version uint
+	jwt.StandardClaims
+}
+
+// guestSecretKey signs guest tokens, falling back to the staff key when no key of its own is set.
+func guestSecretKey() []byte {
+	if key := os.Getenv("GUEST_TOKEN_SECRET_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(SECRET_KEY)
+}
+
+func GenerateGuestToken(tableId uint, version uint) (string, error) {
+	claims := &GuestClaims{
+		TableId: tableId,
+		Version: version,
+		StandardClaims: jwt.StandardClaims{
+			Audience: guestAudience,
+			IssuedAt: time.Now().Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(guestSecretKey())
+}
+
+func ValidateGuestToken(signedToken string) (*GuestClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&GuestClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return guestSecretKey(), nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*GuestClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(guestAudience, true) {
+		return nil, errors.New("the guest token is invalid")
+	}
+	return claims, nil
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+)
+
+// QRCodePNG encodes content as a size by size pixel PNG QR code.
+func QRCodePNG(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+// QRCodeSVG encodes content as a QR code drawn in a size by size SVG, one path for all the dark modules
+// so it scales without blurring when printed.
+func QRCodeSVG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	// the bitmap includes the quiet zone around the code
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, modules, modules)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&svg, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	svg.WriteString(`"/></svg>`)
+	return svg.Bytes(), nil
+}
@@ -1,6 +1,10 @@
 package helpers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	config "github.com/KhetwalDevesh/restaurant-management/database"
 	"github.com/KhetwalDevesh/restaurant-management/models"
@@ -10,81 +14,168 @@ import (
 	"time"
 )
 
+// SignedDetails are carried by access tokens. FamilyId is the refresh token family the token was issued
+// from, so that signing out of it rejects the token too.
 type SignedDetails struct {
-	Email   string
-	Name    string
-	UserId  uint32
-	IsAdmin bool
+	Email    string
+	Name     string
+	UserId   uint32
+	Role     models.Role
+	FamilyId string
 	jwt.StandardClaims
 }
 
 var SECRET_KEY = os.Getenv("TOKEN_SECRET_KEY")
 
-func GenerateAllTokens(email string, name string, userId uint32, isAdmin bool) (signedToken string, signedRefreshToken string, err error) {
-	claims := &SignedDetails{
-		Email:   email,
-		Name:    name,
-		UserId:  userId,
-		IsAdmin: isAdmin,
+// refreshAudience marks refresh tokens, so they are never taken for access tokens.
+const refreshAudience = "refresh"
+
+// AccessTokenLifetime is how long an access token can be used for before it has to be refreshed.
+const AccessTokenLifetime = 24 * time.Hour
+
+// RefreshTokenLifetime is how long a refresh token can be used for before the user has to log in again.
+const RefreshTokenLifetime = 240 * time.Hour
+
+// RefreshClaims are carried by refresh tokens. Id is unique to every token, FamilyId is shared by
+// every token rotated from the same login.
+type RefreshClaims struct {
+	UserId   uint32
+	FamilyId string
+	jwt.StandardClaims
+}
+
+// NewRefreshClaims returns the claims of a new refresh token for the user, starting a new family
+// when familyId is empty.
+func NewRefreshClaims(userId uint32, familyId string) (*RefreshClaims, error) {
+	tokenId, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+	if familyId == "" {
+		if familyId, err = randomId(); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	return &RefreshClaims{
+		UserId:   userId,
+		FamilyId: familyId,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        tokenId,
+			Audience:  refreshAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(RefreshTokenLifetime).Unix(),
 		},
+	}, nil
+}
+
+func randomId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(id), nil
+}
 
-	refreshClaims := &SignedDetails{
+func GenerateAllTokens(email string, name string, userId uint32, role models.Role, refreshClaims *RefreshClaims) (signedToken string, signedRefreshToken string, err error) {
+	claims := &SignedDetails{
+		Email:    email,
+		Name:     name,
+		UserId:   userId,
+		Role:     role,
+		FamilyId: refreshClaims.FamilyId,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(240)).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(AccessTokenLifetime).Unix(),
 		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return "", "", err
+	}
 	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
-
 	if err != nil {
-		log.Panic(err)
-		return
+		return "", "", err
 	}
-
-	return token, refreshToken, err
+	return token, refreshToken, nil
 }
 
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId uint32) {
-	// Assuming you have a User model
-	user := models.User{
-		ID:           userId,
-		Token:        signedToken,
-		RefreshToken: signedRefreshToken,
-		UpdatedAt:    time.Now(),
+// ValidateToken checks an access token. Access tokens carry no audience, which keeps refresh and guest
+// tokens signed with the same key from being used in their place.
+func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&SignedDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, "token is expired"
+		}
+		return nil, "the token is invalid"
 	}
 
-	// Use GORM to update the user details
-	if err := config.GetDB().Model(&user).Updates(user).Error; err != nil {
-		log.Panic(err)
-		return
+	claims, ok := token.Claims.(*SignedDetails)
+	if !ok || !token.Valid || claims.Audience != "" {
+		return nil, "the token is invalid"
 	}
+	return claims, msg
 }
 
-func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+// ValidateRefreshToken checks the signature, audience and expiry of a refresh token. Whether it is
+// still the newest of its family is up to the caller.
+func ValidateRefreshToken(signedToken string) (*RefreshClaims, error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
-		&SignedDetails{},
+		&RefreshClaims{},
 		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
 			return []byte(SECRET_KEY), nil
 		},
 	)
-
-	claims, ok := token.Claims.(*SignedDetails)
-	if !ok {
-		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
-		return
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*RefreshClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(refreshAudience, true) || claims.Id == "" {
+		return nil, errors.New("the refresh token is invalid")
 	}
+	return claims, nil
+}
 
-	//the token is expired
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		msg = fmt.Sprint("token is expired")
-		msg = err.Error()
-		return
+// PruneTokens deletes expired refresh tokens, token revocations and emailed tokens, and ended or expired sessions,
+// every interval until ctx is done. A revocation expires once every access token it rejects has expired on its own.
+func PruneTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		if err := config.GetDB().Where("expires_at < ?", now).Delete(&models.TokenRevocation{}).Error; err != nil {
+			log.Printf("token pruning: revocations were not deleted: %v", err)
+		}
+		if err := config.GetDB().Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+			log.Printf("token pruning: refresh tokens were not deleted: %v", err)
+		}
+		// sign-outs are enforced by the revocations, a session only lives on to be listed
+		if err := config.GetDB().Where("ended_at IS NOT NULL OR expires_at < ?", now).Delete(&models.Session{}).Error; err != nil {
+			log.Printf("token pruning: sessions were not deleted: %v", err)
+		}
+		if err := config.GetDB().Where("expires_at < ?", now).Delete(&models.UserToken{}).Error; err != nil {
+			log.Printf("token pruning: emailed tokens were not deleted: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	return claims, msg
 }
@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	jwt "github.com/dgrijalva/jwt-go"
+	"time"
+)
+
+// UserTokenClaims are carried by the tokens emailed to users. The purpose is the audience,
+// so a token can only be used for what it was issued for and is never taken for an access token.
+type UserTokenClaims struct {
+	UserId uint32
+	jwt.StandardClaims
+}
+
+// UserTokenLifetime is how long an emailed token can be used for.
+func UserTokenLifetime(purpose models.UserTokenPurpose) time.Duration {
+	if purpose == models.ResetPassword {
+		return time.Hour
+	}
+	return 48 * time.Hour
+}
+
+// GenerateUserToken signs a token for the purpose, returning its id along with it.
+func GenerateUserToken(userId uint32, purpose models.UserTokenPurpose) (signedToken string, tokenId string, err error) {
+	tokenId, err = randomId()
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	claims := &UserTokenClaims{
+		UserId: userId,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenId,
+			Audience:  string(purpose),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(UserTokenLifetime(purpose)).Unix(),
+		},
+	}
+	signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return "", "", err
+	}
+	return signedToken, tokenId, nil
+}
+
+// ValidateUserToken checks the signature, purpose and expiry of an emailed token. Whether it was used
+// already is up to the caller.
+func ValidateUserToken(signedToken string, purpose models.UserTokenPurpose) (*UserTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&UserTokenClaims{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return []byte(SECRET_KEY), nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*UserTokenClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(string(purpose), true) || claims.Id == "" {
+		return nil, errors.New("the token is invalid")
+	}
+	return claims, nil
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	jwt "github.com/dgrijalva/jwt-go"
+	"testing"
+	"time"
+)
+
+// useSecretKey signs tokens with key for the rest of the test, putting the key back afterwards.
+func useSecretKey(t *testing.T, key string) {
+	previous := SECRET_KEY
+	t.Cleanup(func() { SECRET_KEY = previous })
+	SECRET_KEY = key
+}
+
+func TestValidateUserToken(t *testing.T) {
+	useSecretKey(t, "test-secret")
+	signedToken, tokenId, err := GenerateUserToken(7, models.ResetPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateUserToken(signedToken, models.ResetPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != 7 || claims.Id != tokenId {
+		t.Errorf("got user %d and token %q, want 7 and %q", claims.UserId, claims.Id, tokenId)
+	}
+}
+
+func TestValidateUserTokenRejectsWrongPurpose(t *testing.T) {
+	useSecretKey(t, "test-secret")
+	signedToken, _, err := GenerateUserToken(7, models.VerifyEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateUserToken(signedToken, models.ResetPassword); err == nil {
+		t.Error("a verification token was accepted for a password reset")
+	}
+}
+
+func TestValidateUserTokenRejectsExpired(t *testing.T) {
+	useSecretKey(t, "test-secret")
+	claims := &UserTokenClaims{
+		UserId: 7,
+		StandardClaims: jwt.StandardClaims{
+			Id:        "expired",
+			Audience:  string(models.ResetPassword),
+			IssuedAt:  time.Now().Add(-2 * time.Hour).Unix(),
+			ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+		},
+	}
+	signedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateUserToken(signedToken, models.ResetPassword); err == nil {
+		t.Error("an expired token was accepted")
+	}
+}
+
+func TestValidateUserTokenRejectsOtherKey(t *testing.T) {
+	useSecretKey(t, "test-secret")
+	signedToken, _, err := GenerateUserToken(7, models.ResetPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SECRET_KEY = "another-secret"
+	if _, err := ValidateUserToken(signedToken, models.ResetPassword); err == nil {
+		t.Error("a token signed with another key was accepted")
+	}
+}
@@ -0,0 +1,46 @@
+package kitchen
+
+import "sync"
+
+// subscriberBuffer is how many events a slow display may fall behind before it is dropped.
+const subscriberBuffer = 64
+
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+var defaultHub = &hub{subscribers: make(map[chan Event]struct{})}
+
+// Subscribe registers a receiver for kitchen events on this instance.
+// The channel is closed if the receiver falls too far behind, in which case it should reconnect and reload the queue.
+// The returned function must be called once the receiver is done.
+func Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+	defaultHub.mu.Lock()
+	defaultHub.subscribers[events] = struct{}{}
+	defaultHub.mu.Unlock()
+	return events, func() { defaultHub.remove(events) }
+}
+
+func (h *hub) remove(events chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[events]; ok {
+		delete(h.subscribers, events)
+		close(events)
+	}
+}
+
+func (h *hub) broadcast(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(h.subscribers, events)
+			close(events)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package kitchen
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+)
+
+// channel is the Postgres NOTIFY channel shared by every server instance.
+const channel = "kitchen_events"
+
+// EventType names the change a kitchen display is told about.
+type EventType string
+
+const (
+	ItemCreated  EventType = "item_created"
+	ItemUpdated  EventType = "item_updated"
+	ItemBumped   EventType = "item_bumped"
+	ItemRecalled EventType = "item_recalled"
+	// ItemFired is sent for every item of a course when the course is fired and the items reach the kitchen.
+	ItemFired EventType = "item_fired"
+	// ItemRemoved is raised locally for station displays when one of their items is sent to another station.
+	ItemRemoved EventType = "item_removed"
+	// Resync is raised locally when events may have been missed and displays should reload the queue.
+	Resync EventType = "resync"
+)
+
+type Event struct {
+	Type        EventType `json:"type"`
+	OrderItemId uint32    `json:"orderItemId"`
+	OrderId     uint32    `json:"orderId"`
+}
+
+// Publish sends the events to every instance listening on the kitchen channel.
+// When db is a transaction the notifications are only delivered once it commits.
+func Publish(db *gorm.DB, events ...Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := db.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
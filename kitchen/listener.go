@@ -0,0 +1,55 @@
+package kitchen
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"log"
+	"time"
+)
+
+const reconnectDelay = 5 * time.Second
+
+// Listen forwards notifications from the kitchen channel to this instance's subscribers
+// until ctx is done, reconnecting whenever the connection drops.
+func Listen(ctx context.Context, dsn string) {
+	for {
+		err := listen(ctx, dsn)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("kitchen listener stopped: %v, reconnecting in %v", err, reconnectDelay)
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func listen(ctx context.Context, dsn string) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return err
+	}
+	// anything published while we were not listening is lost, so have displays reload
+	defaultHub.broadcast(Event{Type: Resync})
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("kitchen listener: ignoring malformed event %q: %v", notification.Payload, err)
+			continue
+		}
+		defaultHub.broadcast(event)
+	}
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FileMailer writes every message to its own .eml file in a directory instead of sending it,
+// for development without a mail server.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes to dir, "mail" if empty.
+func NewFileMailer(dir string, from string) *FileMailer {
+	if dir == "" {
+		dir = "mail"
+	}
+	if from == "" {
+		from = "restaurant@localhost"
+	}
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := checkHeaders(message.To, message.Subject); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(m.dir, fmt.Sprintf("%s-*.eml", time.Now().Format("20060102-150405")))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(format(m.from, message))
+	return err
+}
@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoDriver is returned when MAIL_DRIVER is not set.
+	ErrNoDriver = errors.New("MAIL_DRIVER is not set, use smtp, or file or memory to keep mail from being sent")
+	// ErrUnknownDriver is returned when MAIL_DRIVER names no mailer.
+	ErrUnknownDriver = errors.New("unknown mail driver")
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultMailer Mailer
+	defaultErr    error
+)
+
+// Default returns the mailer set with MAIL_DRIVER: "smtp", "file" or "memory". There is no default,
+// so mail is never quietly kept on disk or in memory instead of being sent.
+// It is set up once and shared, so messages kept by the memory mailer can be read back.
+func Default() (Mailer, error) {
+	defaultOnce.Do(func() {
+		defaultMailer, defaultErr = fromEnv()
+	})
+	return defaultMailer, defaultErr
+}
+
+func fromEnv() (Mailer, error) {
+	driver := os.Getenv("MAIL_DRIVER")
+	switch driver {
+	case "":
+		return nil, ErrNoDriver
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	case "file":
+		return NewFileMailer(os.Getenv("MAIL_DIR"), os.Getenv("MAIL_FROM")), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+}
+
+// format renders the message with its headers, as sent over SMTP and written by the file mailer.
+func format(from string, message Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// checkHeaders rejects line breaks in header values, which would let them add headers of their own.
+func checkHeaders(values ...string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return errors.New("mail headers can not contain line breaks")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFromEnvNeedsDriver(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "")
+	if _, err := fromEnv(); !errors.Is(err, ErrNoDriver) {
+		t.Errorf("got %v without a driver, want %v", err, ErrNoDriver)
+	}
+	t.Setenv("MAIL_DRIVER", "pigeon")
+	if _, err := fromEnv(); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("got %v for an unknown driver, want %v", err, ErrUnknownDriver)
+	}
+	t.Setenv("MAIL_DRIVER", "memory")
+	if m, err := fromEnv(); err != nil {
+		t.Errorf("memory driver: %v", err)
+	} else if _, ok := m.(*MemoryMailer); !ok {
+		t.Errorf("memory driver gave a %T", m)
+	}
+}
+
+func TestMemoryMailerRejectsHeaderInjection(t *testing.T) {
+	m := NewMemoryMailer()
+	err := m.Send(context.Background(), Message{To: "guest@example.com\r\nBcc: someone@example.com", Subject: "Hi"})
+	if err == nil {
+		t.Fatal("a line break in To was accepted")
+	}
+	if len(m.Messages()) != 0 {
+		t.Error("the message was kept")
+	}
+}
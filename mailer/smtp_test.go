@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSMTPSendGivesUpOnSilentServer(t *testing.T) {
+	// accepts connections but never greets, like a stuck server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	m, err := NewSMTPMailer(SMTPConfig{Host: host, Port: port, From: "restaurant@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if err := m.Send(ctx, Message{To: "guest@example.com", Subject: "Hi", Body: "Hello"}); err == nil {
+		t.Fatal("sending to a silent server succeeded")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	config "github.com/KhetwalDevesh/restaurant-management/database"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/kitchen"
+	"github.com/KhetwalDevesh/restaurant-management/mailer"
 	"github.com/KhetwalDevesh/restaurant-management/middleware"
 	"github.com/KhetwalDevesh/restaurant-management/routes"
 	"github.com/gin-gonic/gin"
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -14,15 +22,56 @@ func main() {
 		port = "8000"
 	}
 	config.ConfigDB()
+	// the mailer is set up after ConfigDB has loaded .env, which MAIL_DRIVER may be set in. Without one,
+	// verification and password reset emails aren't sent, which only keeps the server from starting
+	// when REQUIRE_EMAIL_VERIFICATION would leave new accounts unable to log in
+	if _, err := mailer.Default(); err != nil {
+		if required, _ := strconv.ParseBool(os.Getenv("REQUIRE_EMAIL_VERIFICATION")); required {
+			log.Fatal(err)
+		}
+		log.Printf("verification and password reset emails will not be sent: %v", err)
+	}
+	// forward kitchen events published by any instance to this instance's displays
+	go kitchen.Listen(context.Background(), config.GetDSN())
+	// sign-outs only need remembering until the tokens they reject have expired
+	go helper.PruneTokens(context.Background(), time.Hour)
 	router := gin.New()
 	router.Use(gin.Logger())
+	// sessions record the client's IP, only take it from X-Forwarded-For when the request came through one of
+	// the proxies in TRUSTED_PROXIES, a comma separated list of addresses or CIDRs
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		trustedProxies = strings.Split(proxies, ",")
+		for i := range trustedProxies {
+			trustedProxies[i] = strings.TrimSpace(trustedProxies[i])
+		}
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal(err)
+	}
+	routes.GuestRoutes(router)
+	routes.PaymentWebhookRoutes(router)
+	routes.TokenRoutes(router)
 	routes.UserRoutes(router)
 	router.Use(middleware.Authentication())
+	routes.FloorRoutes(router)
 	routes.FoodRoutes(router)
+	routes.GuestSubmissionRoutes(router)
 	routes.InvoiceRoutes(router)
+	routes.KitchenRoutes(router)
+	routes.KitchenStationRoutes(router)
 	routes.MenuRoutes(router)
+	routes.ModifierRoutes(router)
 	routes.OrderRoutes(router)
 	routes.OrderItemRoutes(router)
+	routes.PaymentRoutes(router)
+	routes.PrinterRoutes(router)
+	routes.PricingRuleRoutes(router)
+	routes.PromotionRoutes(router)
+	routes.ReservationRoutes(router)
+	routes.SessionRoutes(router)
 	routes.TableRoutes(router)
+	routes.TaxRateRoutes(router)
+	routes.WaitlistRoutes(router)
 	router.Run(":" + port)
 }
@@ -2,30 +2,71 @@ package middleware
 
 import (
 	"fmt"
+	config "github.com/KhetwalDevesh/restaurant-management/database"
 	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
+	"time"
 )
 
 func Authentication() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
 			c.Abort()
 			return
 		}
 
 		claims, err := helper.ValidateToken(clientToken)
 		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			// 401 tells clients to get a new token from /token/refresh
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err})
 			c.Abort()
 			return
 		}
+		if claims.FamilyId != "" {
+			var revocations int64
+			err := config.GetDB().Model(&models.TokenRevocation{}).Where("family_id = ?", claims.FamilyId).Count(&revocations).Error
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the token"})
+				c.Abort()
+				return
+			}
+			if revocations > 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "this session was logged out, please log in again"})
+				c.Abort()
+				return
+			}
+			// last seen is kept to the minute, saving a write on most requests
+			now := time.Now()
+			err = config.GetDB().Model(&models.Session{}).
+				Where("family_id = ? AND last_seen_at < ?", claims.FamilyId, now.Add(-time.Minute)).
+				Update("last_seen_at", now).Error
+			if err != nil {
+				log.Printf("session %s was not marked as seen: %v", claims.FamilyId, err)
+			}
+		}
 		c.Set("email", claims.Email)
 		c.Set("name", claims.Name)
 		c.Set("uid", claims.UserId)
-		c.Set("isAdmin", claims.IsAdmin)
+		c.Set("role", claims.Role)
+		c.Set("familyId", claims.FamilyId)
+		c.Next()
+	}
+}
+
+// Authorize only lets through users whose role has the permission. It has to come after Authentication.
+func Authorize(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if userRole, ok := role.(models.Role); !ok || !userRole.Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("your role does not have the %s permission", permission)})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
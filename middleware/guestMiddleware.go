@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	config "github.com/KhetwalDevesh/restaurant-management/database"
+	helper "github.com/KhetwalDevesh/restaurant-management/helpers"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GuestAuthentication lets guests holding a table's QR ordering token through, scoped to that table.
+// The token is read from the guest-token header, or the token query parameter the QR code links with.
+func GuestAuthentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		guestToken := c.Request.Header.Get("guest-token")
+		if guestToken == "" {
+			guestToken = c.Query("token")
+		}
+		if guestToken == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No guest token provided"})
+			c.Abort()
+			return
+		}
+
+		claims, err := helper.ValidateGuestToken(guestToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		var table models.Table
+		if err := config.GetDB().Where("id = ?", claims.TableId).First(&table).Error; err != nil || table.GuestTokenVersion != claims.Version {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "this QR code is no longer valid, please ask a member of staff"})
+			c.Abort()
+			return
+		}
+		c.Set("guestTableId", table.ID)
+		c.Set("guestTableNumber", table.TableNumber)
+		c.Next()
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// FloorSection is a part of the dining room, like the patio or the bar, laid out on its own floor plan.
+type FloorSection struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	Name      string    `gorm:"not null;uniqueIndex" json:"name" validate:"required"`
+	SortOrder int       `gorm:"not null;default:0" json:"sortOrder"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
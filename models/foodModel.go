@@ -11,5 +11,6 @@ type Food struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 	MenuId    uint32    `json:"menuId" gorm:"not null"`
 	// Association
-	Menu Menu `gorm:"foreignKey:MenuId"`
+	Menu     Menu          `gorm:"foreignKey:MenuId"`
+	Variants []FoodVariant `gorm:"foreignKey:FoodId" json:"variants,omitempty"`
 }
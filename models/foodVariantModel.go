@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// FoodVariant is an orderable version of a food, such as a portion size, with its own price.
+type FoodVariant struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	FoodId    uint32    `gorm:"not null;index" json:"foodId"`
+	Name      string    `gorm:"not null" json:"name" validate:"required"`
+	Size      Quantity  `json:"size,omitempty" validate:"omitempty,oneof=S M L"`
+	Price     float64   `gorm:"not null" json:"price" validate:"gte=0"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
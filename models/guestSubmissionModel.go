@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// GuestSubmissionStatus represents where items a guest ordered from the table are in staff review.
+type GuestSubmissionStatus string
+
+const (
+	GuestSubmissionPending  GuestSubmissionStatus = "pending"
+	GuestSubmissionApproved GuestSubmissionStatus = "approved"
+	GuestSubmissionRejected GuestSubmissionStatus = "rejected"
+)
+
+// GuestSubmission is a batch of items a guest ordered by scanning the table's QR code.
+// The items only become order items, on the table's open order, once a member of staff approves them.
+type GuestSubmission struct {
+	ID         uint32                `gorm:"primary_key" json:"id"`
+	TableId    uint                  `gorm:"not null;index" json:"tableId"`
+	GuestName  string                `json:"guestName,omitempty"`
+	Status     GuestSubmissionStatus `gorm:"not null;default:pending;index" json:"status"`
+	OrderId    *uint32               `json:"orderId,omitempty"` // set once approved
+	Reason     string                `json:"reason,omitempty"`  // why staff rejected it
+	ReviewedBy *uint32               `json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time            `json:"reviewedAt,omitempty"`
+	Items      []GuestSubmissionItem `gorm:"foreignKey:SubmissionId" json:"items" validate:"required,min=1,max=50,dive"`
+	CreatedAt  time.Time             `json:"createdAt"`
+	UpdatedAt  time.Time             `json:"updatedAt"`
+}
+
+// GuestSubmissionItem is one item a guest asked for, priced and checked again when it is approved.
+type GuestSubmissionItem struct {
+	ID            uint32   `gorm:"primary_key" json:"id"`
+	SubmissionId  uint32   `gorm:"not null;index" json:"submissionId"`
+	FoodId        uint32   `gorm:"not null" json:"foodId" validate:"required"`
+	FoodVariantId *uint32  `json:"foodVariantId,omitempty"`
+	Quantity      uint32   `gorm:"not null" json:"quantity" validate:"gt=0,lte=20"`
+	Seat          uint32   `gorm:"not null;default:0" json:"seat"`
+	Notes         string   `json:"notes,omitempty" validate:"max=200"`
+	ModifierIds   []uint32 `gorm:"serializer:json" json:"modifierIds,omitempty"`
+	// UnitPrice is what the item cost when it was submitted, shown to the guest before approval
+	UnitPrice float64 `gorm:"not null;default:0" json:"unitPrice"`
+}
@@ -12,19 +12,50 @@ const (
 	Cash PaymentMethod = "cash"
 )
 
+// PaymentStatus is derived from the payments and refunds recorded against an invoice.
 type PaymentStatus string
 
 const (
-	Pending PaymentStatus = "pending"
-	Paid    PaymentStatus = "paid"
+	Pending           PaymentStatus = "pending"
+	PartiallyPaid     PaymentStatus = "partially_paid"
+	Paid              PaymentStatus = "paid"
+	PartiallyRefunded PaymentStatus = "partially_refunded"
+	Refunded          PaymentStatus = "refunded"
+)
+
+// Settled reports whether the invoice was paid in full, refunds given afterwards don't unsettle it.
+func (s PaymentStatus) Settled() bool {
+	return s == Paid || s == PartiallyRefunded || s == Refunded
+}
+
+// SplitMode represents how an order's bill was split across invoices.
+type SplitMode string
+
+const (
+	EvenSplit  SplitMode = "even"
+	SeatSplit  SplitMode = "seat"
+	ItemsSplit SplitMode = "items"
 )
 
 type Invoice struct {
 	ID             uint32        `gorm:"primary_key" json:"id"`
 	OrderID        uint32        `gorm:"not null" json:"orderID"`
 	PaymentMethod  PaymentMethod `gorm:"not null" json:"paymentMethod" validate:"oneof=card cash"`
-	PaymentStatus  PaymentStatus `gorm:"not null" json:"paymentStatus" validate:"oneof=pending paid"`
+	PaymentStatus  PaymentStatus `gorm:"not null" json:"paymentStatus" validate:"oneof=pending partially_paid paid partially_refunded refunded"`
 	PaymentDueDate time.Time     `json:"paymentDueDate"`
-	CreatedAt      time.Time     `json:"createdAt"`
-	UpdatedAt      time.Time     `json:"updatedAt"`
+	// SplitMode is empty for an invoice covering the whole order, otherwise the invoice is
+	// part SplitIndex of SplitCount and only covers the order items allocated to it
+	SplitMode  SplitMode `json:"splitMode,omitempty" validate:"omitempty,oneof=even seat items"`
+	SplitIndex uint32    `json:"splitIndex,omitempty"`
+	SplitCount uint32    `json:"splitCount,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// InvoiceItem allocates a share of an order item to a split invoice, 0.5 meaning half of it.
+type InvoiceItem struct {
+	ID          uint32  `gorm:"primary_key" json:"id"`
+	InvoiceId   uint32  `gorm:"not null;index" json:"invoiceId"`
+	OrderItemId uint32  `gorm:"not null;index" json:"orderItemId"`
+	Share       float64 `gorm:"not null" json:"share"`
 }
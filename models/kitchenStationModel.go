@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// KitchenStation is a part of the kitchen that prepares its own share of the order items,
+// like the grill or the bar. Its tickets go to PrinterId when it has a printer. Name is kept
+// short enough to share the line under the ticket header with the time it was printed.
+type KitchenStation struct {
+	ID        uint32         `gorm:"primary_key" json:"id"`
+	Name      string         `gorm:"not null;uniqueIndex" json:"name" validate:"required,max=40"`
+	PrinterId *uint32        `json:"printerId,omitempty"`
+	Active    bool           `gorm:"not null;default:true" json:"active"`
+	Routes    []StationRoute `gorm:"foreignKey:StationId" json:"routes,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// StationRoute sends the order items of a food, or of every food on menus of a category, to a station.
+// Routes for a food win over routes for its category.
+type StationRoute struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	StationId uint32    `gorm:"not null;index" json:"stationId"`
+	FoodId    *uint32   `gorm:"uniqueIndex" json:"foodId,omitempty" validate:"required_without=Category,excluded_with=Category"`
+	Category  *string   `gorm:"uniqueIndex" json:"category,omitempty" validate:"required_without=FoodId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
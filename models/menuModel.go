@@ -10,4 +10,6 @@ type Menu struct {
 	EndDate   time.Time `json:"endDate"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// Association
+	Foods []Food `gorm:"foreignKey:MenuId" json:"foods,omitempty"`
 }
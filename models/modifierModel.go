@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ModifierGroup is a set of choices offered with a food, such as "Toppings" or "Cooking".
+// A required group needs at least one choice even when MinChoices is 0, a MaxChoices of 0 means no limit.
+type ModifierGroup struct {
+	ID         uint32    `gorm:"primary_key" json:"id"`
+	FoodId     uint32    `gorm:"not null;index" json:"foodId"`
+	Name       string    `gorm:"not null" json:"name" validate:"required"`
+	Required   bool      `gorm:"not null;default:false" json:"required"`
+	MinChoices uint32    `gorm:"not null;default:0" json:"minChoices"`
+	MaxChoices uint32    `gorm:"not null;default:0" json:"maxChoices"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// Association
+	Modifiers []Modifier `gorm:"foreignKey:ModifierGroupId" json:"modifiers" validate:"dive"`
+}
+
+// MinimumChoices returns how many modifiers have to be chosen from the group.
+func (g ModifierGroup) MinimumChoices() uint32 {
+	if g.Required && g.MinChoices == 0 {
+		return 1
+	}
+	return g.MinChoices
+}
+
+// Modifier is a single choice in a group, e.g. "Extra cheese" costing 1.50 more.
+type Modifier struct {
+	ID              uint32    `gorm:"primary_key" json:"id"`
+	ModifierGroupId uint32    `gorm:"not null;index" json:"modifierGroupId"`
+	Name            string    `gorm:"not null" json:"name" validate:"required"`
+	PriceDelta      float64   `gorm:"not null;default:0" json:"priceDelta"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+// OrderItemModifier is a modifier chosen for an order item, with its name and price as they were when ordered.
+type OrderItemModifier struct {
+	ID              uint32    `gorm:"primary_key" json:"id"`
+	OrderItemId     uint32    `gorm:"not null;index" json:"orderItemId"`
+	ModifierId      uint32    `gorm:"not null" json:"modifierId"`
+	ModifierGroupId uint32    `gorm:"not null" json:"modifierGroupId"`
+	Name            string    `gorm:"not null" json:"name"`
+	PriceDelta      float64   `gorm:"not null" json:"priceDelta"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
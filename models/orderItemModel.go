@@ -2,6 +2,7 @@ package models
 
 import "time"
 
+// Quantity represents the portion size of a food variant.
 type Quantity string
 
 const (
@@ -10,15 +11,42 @@ const (
 	L Quantity = "L"
 )
 
+// PrepStatus represents how far the kitchen has got with an order item.
+type PrepStatus string
+
+const (
+	PrepQueued    PrepStatus = "queued"
+	PrepPreparing PrepStatus = "preparing"
+	PrepReady     PrepStatus = "ready"
+)
+
 type OrderItem struct {
-	ID        uint32  `gorm:"primary_key" json:"id"`
-	Quantity  uint32  `json:"quantity" gorm:"not null"`
-	UnitPrice float64 `json:"unitPrice" gorm:"not null"`
-	FoodId    uint32  `gorm:"not null" json:"foodId"`
-	OrderId   uint32  `gorm:"not null" json:"orderId"`
+	ID         uint32     `gorm:"primary_key" json:"id"`
+	Quantity   uint32     `json:"quantity" gorm:"not null"`
+	UnitPrice  float64    `json:"unitPrice" gorm:"not null"`
+	FoodId     uint32     `gorm:"not null" json:"foodId"`
+	OrderId    uint32     `gorm:"not null" json:"orderId"`
+	Seat       uint32     `gorm:"not null;default:0" json:"seat"` // 0 when the item is shared by the table
+	PrepStatus PrepStatus `gorm:"not null;default:queued" json:"prepStatus" validate:"omitempty,oneof=queued preparing ready"`
+	BumpedAt   *time.Time `json:"bumpedAt"`
+	StationId  *uint32    `gorm:"index" json:"stationId"`           // nil when no station is routed the item
+	Course     uint32     `gorm:"not null;default:0" json:"course"` // 0 goes to the kitchen straight away, later courses wait to be fired
+	FiredAt    *time.Time `json:"firedAt"`                          // nil while the item's course is held back from the kitchen
+	// FoodVariantId is the variant that was ordered, VariantName keeps its name as it was at the time
+	FoodVariantId *uint32 `json:"foodVariantId"`
+	VariantName   string  `json:"variantName,omitempty"`
+	// ListPrice is the price resolved by the server, UnitPrice only differs from it when a manager overrode it
+	ListPrice           float64 `json:"listPrice"`
+	PriceOverrideReason string  `json:"priceOverrideReason,omitempty"`
+	PriceOverriddenBy   *uint32 `json:"priceOverriddenBy,omitempty"`
+	// Notes holds free-text instructions for the kitchen, ModifierIds the modifiers picked when ordering
+	Notes       string   `json:"notes,omitempty"`
+	ModifierIds []uint32 `gorm:"-" json:"modifierIds,omitempty"`
 	// Associations
-	Food      Food      `gorm:"foreignKey:FoodId;onDelete:CASCADE"`
-	Order     Order     `gorm:"foreignKey:OrderId;onDelete:CASCADE"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	Food        Food                `gorm:"foreignKey:FoodId;onDelete:CASCADE"`
+	FoodVariant *FoodVariant        `gorm:"foreignKey:FoodVariantId" json:",omitempty"`
+	Modifiers   []OrderItemModifier `gorm:"foreignKey:OrderItemId" json:"modifiers,omitempty"`
+	Order       Order               `gorm:"foreignKey:OrderId;onDelete:CASCADE" validate:"-"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
 }
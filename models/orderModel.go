@@ -2,13 +2,132 @@ package models
 
 import "time"
 
+// OrderStatus represents the lifecycle state of an order.
+type OrderStatus string
+
+const (
+	OrderPlaced        OrderStatus = "placed"
+	OrderAccepted      OrderStatus = "accepted"
+	OrderInPreparation OrderStatus = "in_preparation"
+	OrderReady         OrderStatus = "ready"
+	OrderServed        OrderStatus = "served"
+	OrderClosed        OrderStatus = "closed"
+	OrderCancelled     OrderStatus = "cancelled"
+	OrderVoided        OrderStatus = "voided"
+	// OrderMerged orders had their items moved into another order, MergedIntoId, and are done with
+	OrderMerged OrderStatus = "merged"
+)
+
+// orderStatusTransitions lists the states an order may move to from each state.
+// Orders can be cancelled until the kitchen starts on them, after that they can only be voided.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderPlaced:        {OrderAccepted, OrderCancelled},
+	OrderAccepted:      {OrderInPreparation, OrderCancelled},
+	OrderInPreparation: {OrderReady, OrderVoided},
+	OrderReady:         {OrderServed, OrderVoided},
+	OrderServed:        {OrderClosed, OrderVoided},
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible from s.
+func (s OrderStatus) IsTerminal() bool {
+	return len(orderStatusTransitions[s]) == 0
+}
+
+// OrderType tells how an order reaches the guest.
+type OrderType string
+
+const (
+	DineIn   OrderType = "dine_in"
+	Takeaway OrderType = "takeaway"
+	Delivery OrderType = "delivery"
+)
+
 type Order struct {
-	ID        uint32    `gorm:"primary_key" json:"id"`
-	OrderDate time.Time `gorm:"not null" json:"orderDate"`
-	TableId   uint32    `gorm:"not null" json:"tableId"`
-	Table     Table     `gorm:"foreignKey:TableId;OnDelete:CASCADE;OnUpdate:CASCADE"`
-	UserId    uint32    `gorm:"not null" json:"userId"`
-	User      User      `gorm:"foreignKey:UserId;OnDelete:CASCADE;OnUpdate:CASCADE"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        uint32      `gorm:"primary_key" json:"id"`
+	OrderDate time.Time   `gorm:"not null" json:"orderDate"`
+	Status    OrderStatus `gorm:"not null;default:placed" json:"status" validate:"oneof=placed accepted in_preparation ready served closed cancelled voided merged"`
+	Type      OrderType   `gorm:"not null;default:dine_in;index" json:"type" validate:"oneof=dine_in takeaway delivery"`
+	// TableId is only set for dine-in orders
+	TableId *uint32 `json:"tableId,omitempty" validate:"required_if=Type dine_in"`
+	Table   *Table  `gorm:"foreignKey:TableId;OnDelete:CASCADE;OnUpdate:CASCADE" json:",omitempty"`
+	UserId  uint32  `gorm:"not null" json:"userId"`
+	User    User    `gorm:"foreignKey:UserId;OnDelete:CASCADE;OnUpdate:CASCADE" validate:"-"`
+	// CustomerName and CustomerPhone say who takeaway and delivery orders are for, PickupAt when a takeaway is collected
+	CustomerName  string     `json:"customerName,omitempty" validate:"required_unless=Type dine_in"`
+	CustomerPhone string     `json:"customerPhone,omitempty" validate:"required_if=Type delivery"`
+	PickupAt      *time.Time `json:"pickupAt,omitempty" validate:"required_if=Type takeaway"`
+	// DeliveryAddress and DeliveryFee are only set for delivery orders, DriverId once a driver is assigned
+	DeliveryAddress string  `json:"deliveryAddress,omitempty" validate:"required_if=Type delivery"`
+	DeliveryFee     float64 `gorm:"not null;default:0" json:"deliveryFee" validate:"gte=0"`
+	DriverId        *uint32 `gorm:"index" json:"driverId,omitempty"`
+	// MergedIntoId is the order this one was merged into, once its status is merged
+	MergedIntoId *uint32   `json:"mergedIntoId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// TrimToType clears whatever the order carries that does not apply to its type, dine-in being the default.
+func (o *Order) TrimToType() {
+	if o.Type == "" {
+		o.Type = DineIn
+	}
+	if o.Type != DineIn {
+		o.TableId = nil
+	}
+	if o.Type == DineIn {
+		o.CustomerName = ""
+		o.CustomerPhone = ""
+	}
+	if o.Type != Takeaway {
+		o.PickupAt = nil
+	}
+	if o.Type != Delivery {
+		o.DeliveryAddress = ""
+		o.DeliveryFee = 0
+		o.DriverId = nil
+	}
+}
+
+// OrderHistoryAction identifies what kind of change an OrderHistory entry records.
+type OrderHistoryAction string
+
+const (
+	OrderHistoryStatus      OrderHistoryAction = "status"
+	OrderHistoryCourseFired OrderHistoryAction = "course_fired"
+	// OrderHistoryTransferred entries record the whole order moving from FromTableId to ToTableId
+	OrderHistoryTransferred OrderHistoryAction = "transferred"
+	// OrderHistoryItemsMoved entries record items moving to or from RelatedOrderId, on both orders
+	OrderHistoryItemsMoved OrderHistoryAction = "items_moved"
+	// OrderHistoryMerged entries record RelatedOrderId being merged into the order, or the order into it
+	OrderHistoryMerged OrderHistoryAction = "merged"
+	// OrderHistoryGuestItems entries record items guests ordered from the table being approved onto the order
+	OrderHistoryGuestItems OrderHistoryAction = "guest_items"
+	// OrderHistoryDriverAssigned entries record a delivery order being given to a driver or taken off one
+	OrderHistoryDriverAssigned OrderHistoryAction = "driver_assigned"
+)
+
+// OrderHistory records who changed an order and when.
+type OrderHistory struct {
+	ID         uint32             `gorm:"primary_key" json:"id"`
+	OrderId    uint32             `gorm:"not null;index" json:"orderId"`
+	Action     OrderHistoryAction `gorm:"not null" json:"action"`
+	FromStatus OrderStatus        `json:"fromStatus,omitempty"`
+	ToStatus   OrderStatus        `json:"toStatus,omitempty"`
+	Note       string             `json:"note,omitempty"`
+	// FromTableId, ToTableId and RelatedOrderId are only set for transfers, moves and merges
+	FromTableId    *uint32   `json:"fromTableId,omitempty"`
+	ToTableId      *uint32   `json:"toTableId,omitempty"`
+	RelatedOrderId *uint32   `json:"relatedOrderId,omitempty"`
+	UserId         uint32    `gorm:"not null" json:"userId"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
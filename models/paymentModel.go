@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// PaymentKind tells tenders taken from a guest from money given back to them.
+type PaymentKind string
+
+const (
+	PaymentTender PaymentKind = "payment"
+	PaymentRefund PaymentKind = "refund"
+)
+
+const (
+	// ProviderPending is the provider status of card payments and refunds recorded ahead of the provider call.
+	ProviderPending = "pending"
+	// ProviderFailed is the provider status of card payments and refunds the provider turned down.
+	ProviderFailed = "failed"
+)
+
+// Payment is one entry of an invoice's payments ledger. Amounts are always positive, Kind says
+// which way the money went. Tip is on top of Amount and doesn't count towards the invoice total.
+// Refunds point at the payment they give money back for through RefundOfId. Card payments and
+// their refunds go through a payment provider, ProviderStatus being the provider's last word on them.
+// They are recorded before the provider is called and stay on the ledger as failed if it turns them down.
+// Payments taken with an IdempotencyKey are only ever taken once for that key.
+type Payment struct {
+	ID                    uint32        `gorm:"primary_key" json:"id"`
+	InvoiceId             uint32        `gorm:"not null;index" json:"invoiceId"`
+	Kind                  PaymentKind   `gorm:"not null" json:"kind" validate:"oneof=payment refund"`
+	Method                PaymentMethod `gorm:"not null" json:"method" validate:"oneof=card cash"`
+	Amount                float64       `gorm:"not null" json:"amount" validate:"gte=0"`
+	Tip                   float64       `gorm:"not null;default:0" json:"tip" validate:"gte=0"`
+	Reference             string        `json:"reference,omitempty"`
+	Reason                string        `json:"reason,omitempty" validate:"required_if=Kind refund"`
+	RefundOfId            *uint32       `gorm:"index" json:"refundOfId,omitempty" validate:"required_if=Kind refund"`
+	UserId                uint32        `json:"userId"`
+	Provider              string        `json:"provider,omitempty"`
+	ProviderTransactionId string        `gorm:"index" json:"providerTransactionId,omitempty"`
+	ProviderStatus        string        `json:"providerStatus,omitempty"`
+	IdempotencyKey        *string       `gorm:"uniqueIndex" json:"idempotencyKey,omitempty"`
+	CreatedAt             time.Time     `json:"createdAt"`
+}
+
+// Failed tells whether the payment provider turned the payment or refund down, so it counts for nothing.
+func (p Payment) Failed() bool {
+	return p.ProviderStatus == ProviderFailed
+}
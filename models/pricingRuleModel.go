@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// AdjustmentType represents how a pricing rule changes a price.
+type AdjustmentType string
+
+const (
+	PercentAdjustment AdjustmentType = "percent"
+	FixedAdjustment   AdjustmentType = "fixed"
+)
+
+// PricingRule adjusts food prices while it is in effect, e.g. a happy hour.
+// A rule with neither FoodId nor MenuId applies to every food.
+// A rule with both only applies to that food, and only while it is on that menu.
+// Amount is a percentage for percent rules and a currency amount for fixed rules; negative amounts lower the price.
+type PricingRule struct {
+	ID             uint32         `gorm:"primary_key" json:"id"`
+	Name           string         `gorm:"not null" json:"name" validate:"required"`
+	FoodId         *uint32        `json:"foodId"`
+	MenuId         *uint32        `json:"menuId"`
+	AdjustmentType AdjustmentType `gorm:"not null" json:"adjustmentType" validate:"oneof=percent fixed"`
+	Amount         float64        `gorm:"not null" json:"amount"`
+	StartsAt       *time.Time     `json:"startsAt"`
+	EndsAt         *time.Time     `json:"endsAt"`
+	DailyStart     string         `json:"dailyStart" validate:"omitempty,datetime=15:04"`
+	DailyEnd       string         `json:"dailyEnd" validate:"omitempty,datetime=15:04"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
+
+// AppliesAt reports whether the rule is in effect at the given time.
+func (r PricingRule) AppliesAt(at time.Time) bool {
+	if r.StartsAt != nil && at.Before(*r.StartsAt) {
+		return false
+	}
+	if r.EndsAt != nil && !at.Before(*r.EndsAt) {
+		return false
+	}
+	if r.DailyStart == "" || r.DailyEnd == "" {
+		return true
+	}
+	clock := at.Format("15:04")
+	if r.DailyStart <= r.DailyEnd {
+		return clock >= r.DailyStart && clock < r.DailyEnd
+	}
+	// the window runs past midnight, e.g. 22:00 - 02:00
+	return clock >= r.DailyStart || clock < r.DailyEnd
+}
+
+// Apply returns price adjusted by the rule, never going below zero.
+func (r PricingRule) Apply(price float64) float64 {
+	switch r.AdjustmentType {
+	case PercentAdjustment:
+		price += price * r.Amount / 100
+	case FixedAdjustment:
+		price += r.Amount
+	}
+	if price < 0 {
+		return 0
+	}
+	return price
+}
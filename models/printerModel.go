@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PrinterKind is what a printer is used for.
+type PrinterKind string
+
+const (
+	ReceiptPrinter PrinterKind = "receipt"
+	KitchenPrinter PrinterKind = "kitchen"
+)
+
+// Printer is a network ESC/POS printer, Address being "host" or "host:port" with 9100 as the default port.
+// Active kitchen printers get a ticket for every batch of new order items.
+type Printer struct {
+	ID        uint32      `gorm:"primary_key" json:"id"`
+	Name      string      `gorm:"not null;uniqueIndex" json:"name" validate:"required"`
+	Address   string      `gorm:"not null" json:"address" validate:"required"`
+	Kind      PrinterKind `gorm:"not null" json:"kind" validate:"oneof=receipt kitchen"`
+	Active    bool        `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// DiscountKind represents how a promotion takes money off.
+type DiscountKind string
+
+const (
+	PercentageDiscount DiscountKind = "percentage"
+	FixedDiscount      DiscountKind = "fixed"
+)
+
+// DiscountScope represents what a promotion takes money off.
+type DiscountScope string
+
+const (
+	// OrderScope discounts the whole invoice
+	OrderScope DiscountScope = "order"
+	// ItemScope discounts every unit of the promotion's food
+	ItemScope DiscountScope = "item"
+)
+
+// Promotion is a discount that can be applied to invoices. Promotions with a Code are coupons
+// that guests have to hand in. A UsageLimit of 0 means the promotion can be used any number of times.
+type Promotion struct {
+	ID         uint32        `gorm:"primary_key" json:"id"`
+	Name       string        `gorm:"not null" json:"name" validate:"required"`
+	Code       *string       `gorm:"uniqueIndex" json:"code,omitempty"`
+	Kind       DiscountKind  `gorm:"not null" json:"kind" validate:"oneof=percentage fixed"`
+	Value      float64       `gorm:"not null" json:"value" validate:"gt=0"`
+	Scope      DiscountScope `gorm:"not null" json:"scope" validate:"oneof=order item"`
+	FoodId     *uint32       `json:"foodId,omitempty" validate:"required_if=Scope item"`
+	MinSpend   float64       `gorm:"not null;default:0" json:"minSpend" validate:"gte=0"`
+	StartsAt   *time.Time    `json:"startsAt"`
+	EndsAt     *time.Time    `json:"endsAt"`
+	UsageLimit uint32        `gorm:"not null;default:0" json:"usageLimit"`
+	TimesUsed  uint32        `gorm:"not null;default:0" json:"timesUsed"`
+	Active     bool          `gorm:"not null;default:true" json:"active"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+}
+
+// ValidAt reports whether the promotion can be used at the given time.
+func (p Promotion) ValidAt(at time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !at.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// InvoiceDiscount records a promotion applied to an invoice, keeping the terms of the promotion
+// as they were when it was applied. Amount is what the discount came to at that time.
+type InvoiceDiscount struct {
+	ID          uint32        `gorm:"primary_key" json:"id"`
+	InvoiceId   uint32        `gorm:"not null;index;uniqueIndex:idx_invoice_discount_promotion" json:"invoiceId"`
+	PromotionId uint32        `gorm:"not null;index;uniqueIndex:idx_invoice_discount_promotion" json:"promotionId"`
+	Name        string        `gorm:"not null" json:"name"`
+	Code        string        `json:"code,omitempty"`
+	Kind        DiscountKind  `gorm:"not null" json:"kind"`
+	Value       float64       `gorm:"not null" json:"value"`
+	Scope       DiscountScope `gorm:"not null" json:"scope"`
+	FoodId      *uint32       `json:"foodId,omitempty"`
+	MinSpend    float64       `gorm:"not null;default:0" json:"minSpend"`
+	Amount      float64       `gorm:"not null;default:0" json:"amount"`
+	UserId      uint32        `gorm:"not null" json:"userId"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RefreshToken is a refresh token handed out to a user. Every token rotated from the same login shares
+// a FamilyId and only the newest of them may be used. An older one coming back means it leaked,
+// so the whole family is revoked and the user has to log in again.
+type RefreshToken struct {
+	ID        uint32     `gorm:"primary_key" json:"id"`
+	TokenId   string     `gorm:"not null;uniqueIndex" json:"-"`
+	FamilyId  string     `gorm:"not null;index" json:"familyId"`
+	UserId    uint32     `gorm:"not null;index" json:"userId"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// ReservationStatus represents where a booking is in its lifecycle.
+type ReservationStatus string
+
+const (
+	ReservationBooked    ReservationStatus = "booked"
+	ReservationSeated    ReservationStatus = "seated"
+	ReservationCompleted ReservationStatus = "completed"
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationNoShow    ReservationStatus = "no_show"
+)
+
+// reservationStatusTransitions lists the states a reservation may move to from each state.
+// Seating can be undone, and cancelled or missed bookings can be taken up again.
+var reservationStatusTransitions = map[ReservationStatus][]ReservationStatus{
+	ReservationBooked:    {ReservationSeated, ReservationCancelled, ReservationNoShow},
+	ReservationSeated:    {ReservationBooked, ReservationCompleted},
+	ReservationCancelled: {ReservationBooked},
+	ReservationNoShow:    {ReservationBooked},
+}
+
+// CanTransitionTo reports whether a reservation in status s may move to next.
+func (s ReservationStatus) CanTransitionTo(next ReservationStatus) bool {
+	for _, allowed := range reservationStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldsTable reports whether a reservation in this status keeps its table from being booked by others.
+func (s ReservationStatus) HoldsTable() bool {
+	return s == ReservationBooked || s == ReservationSeated
+}
+
+// Reservation books a table for a party from StartsAt until EndsAt.
+type Reservation struct {
+	ID        uint32            `gorm:"primary_key" json:"id"`
+	GuestName string            `gorm:"not null" json:"guestName" validate:"required"`
+	PartySize uint              `gorm:"not null" json:"partySize" validate:"gt=0"`
+	Phone     string            `json:"phone" validate:"required_without=Email"`
+	Email     string            `json:"email" validate:"omitempty,email"`
+	Notes     string            `json:"notes,omitempty"`
+	StartsAt  time.Time         `gorm:"not null;index" json:"startsAt" validate:"required"`
+	EndsAt    time.Time         `gorm:"not null;index" json:"endsAt" validate:"required,gtfield=StartsAt"`
+	TableId   uint              `gorm:"not null;index" json:"tableId"`
+	Status    ReservationStatus `gorm:"not null;default:booked" json:"status" validate:"oneof=booked seated completed cancelled no_show"`
+	UserId    uint32            `json:"userId"`
+	Table     Table             `gorm:"foreignKey:TableId"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
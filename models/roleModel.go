@@ -0,0 +1,90 @@
+package models
+
+// Role is what a member of staff does in the restaurant, deciding which permissions they have.
+type Role string
+
+const (
+	RoleOwner   Role = "owner"
+	RoleManager Role = "manager"
+	RoleCashier Role = "cashier"
+	RoleWaiter  Role = "waiter"
+	RoleChef    Role = "chef"
+	RoleHost    Role = "host"
+)
+
+// Roles lists every role, from the most to the least trusted.
+var Roles = []Role{RoleOwner, RoleManager, RoleCashier, RoleWaiter, RoleChef, RoleHost}
+
+// Permission is something a role may be allowed to do. Reading menus, orders and the floor
+// only needs a login, everything that changes them needs a permission.
+type Permission string
+
+const (
+	// PermissionManageMenu covers foods, their variants and modifiers, and menus
+	PermissionManageMenu Permission = "manage_menu"
+	// PermissionManagePricing covers pricing rules, promotions and tax rates
+	PermissionManagePricing Permission = "manage_pricing"
+	// PermissionManageFloor covers tables, floor sections and revoking a table's QR codes
+	PermissionManageFloor Permission = "manage_floor"
+	// PermissionManageSetup covers printers and kitchen stations
+	PermissionManageSetup Permission = "manage_setup"
+	// PermissionManageUsers covers listing staff accounts, assigning their roles and logging them out
+	PermissionManageUsers Permission = "manage_users"
+	// PermissionViewSessions covers seeing where every member of staff is logged in
+	PermissionViewSessions Permission = "view_sessions"
+	// PermissionTakeOrders covers creating and changing orders and their items, and approving guest orders
+	PermissionTakeOrders Permission = "take_orders"
+	// PermissionOverridePrices lets an order item be charged at another price than the menu's
+	PermissionOverridePrices Permission = "override_prices"
+	// PermissionBill covers invoices, discounts on them, split bills and taking payments
+	PermissionBill Permission = "bill"
+	// PermissionRefund covers refunding payments
+	PermissionRefund Permission = "refund"
+	// PermissionWorkKitchen covers bumping and recalling items on the kitchen display
+	PermissionWorkKitchen Permission = "work_kitchen"
+	// PermissionSeatGuests covers reservations, the waitlist and marking tables dirty or clean
+	PermissionSeatGuests Permission = "seat_guests"
+)
+
+// rolePermissions is the permission matrix. Owners can do everything.
+var rolePermissions = map[Role][]Permission{
+	RoleManager: {
+		PermissionManageMenu, PermissionManagePricing, PermissionManageFloor, PermissionManageSetup,
+		PermissionViewSessions, PermissionTakeOrders, PermissionOverridePrices, PermissionBill, PermissionRefund,
+		PermissionWorkKitchen, PermissionSeatGuests,
+	},
+	RoleCashier: {PermissionTakeOrders, PermissionBill},
+	RoleWaiter:  {PermissionTakeOrders, PermissionSeatGuests},
+	RoleChef:    {PermissionWorkKitchen},
+	RoleHost:    {PermissionSeatGuests},
+}
+
+// Can reports whether r has the permission.
+func (r Role) Can(permission Permission) bool {
+	if r == RoleOwner {
+		return true
+	}
+	for _, allowed := range rolePermissions[r] {
+		if allowed == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether r manages the restaurant, which is what the IsAdmin flag of a user meant before roles.
+func (r Role) IsAdmin() bool {
+	return r == RoleOwner || r == RoleManager
+}
+
+// Permissions lists what r is allowed to do.
+func (r Role) Permissions() []Permission {
+	if r == RoleOwner {
+		return []Permission{
+			PermissionManageMenu, PermissionManagePricing, PermissionManageFloor, PermissionManageSetup,
+			PermissionManageUsers, PermissionViewSessions, PermissionTakeOrders, PermissionOverridePrices, PermissionBill,
+			PermissionRefund, PermissionWorkKitchen, PermissionSeatGuests,
+		}
+	}
+	return rolePermissions[r]
+}
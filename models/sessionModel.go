@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Session is a login on a device, kept going by its refresh token family. LastSeenAt is bumped as
+// its access tokens are used, ExpiresAt as its refresh token is rotated. Logging out ends it.
+type Session struct {
+	ID         uint32     `gorm:"primary_key" json:"id"`
+	FamilyId   string     `gorm:"not null;uniqueIndex" json:"-"`
+	UserId     uint32     `gorm:"not null;index" json:"userId"`
+	DeviceName string     `json:"deviceName"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `gorm:"not null" json:"lastSeenAt"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expiresAt"`
+	EndedAt    *time.Time `gorm:"index" json:"endedAt,omitempty"`
+	Current    bool       `gorm:"-" json:"current"`
+}
@@ -2,10 +2,30 @@ package models
 
 import "time"
 
+// TableStatus represents what is going on at a table right now.
+type TableStatus string
+
+const (
+	TableFree     TableStatus = "free"
+	TableOccupied TableStatus = "occupied"
+	// TableAwaitingPayment tables have been billed and not every invoice has been paid yet
+	TableAwaitingPayment TableStatus = "awaiting_payment"
+	TableDirty           TableStatus = "dirty"
+	TableCleaning        TableStatus = "cleaning"
+)
+
 type Table struct {
-	ID             uint      `gorm:"primary_key" json:"id"`
-	NumberOfGuests uint      `json:"numberOfGuests"`
-	TableNumber    uint      `json:"tableNumber"`
-	CreatedAt      time.Time `json:"createdAt"`
-	UpdatedAt      time.Time `json:"updatedAt"`
+	ID             uint `gorm:"primary_key" json:"id"`
+	NumberOfGuests uint `json:"numberOfGuests"`
+	TableNumber    uint `json:"tableNumber"`
+	// SectionId is the floor section the table is in, X and Y place it on the section's floor plan
+	SectionId *uint   `gorm:"index" json:"sectionId,omitempty"`
+	X         float64 `gorm:"not null;default:0" json:"x"`
+	Y         float64 `gorm:"not null;default:0" json:"y"`
+	// ManualStatus is set by staff while a free table is being turned over, empty once it is ready again
+	ManualStatus TableStatus `json:"manualStatus,omitempty" validate:"omitempty,oneof=dirty cleaning"`
+	// GuestTokenVersion is signed into the table's QR ordering token, bumping it revokes every code printed so far
+	GuestTokenVersion uint      `gorm:"not null;default:0" json:"-"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
 }
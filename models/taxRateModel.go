@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TaxRate is a tax charged on food, Rate being a percentage. It applies to the food it names,
+// otherwise to the foods on menus of its category, otherwise to every food.
+// Inclusive taxes are already part of the menu price, exclusive ones are added on top of it.
+type TaxRate struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	Name      string    `gorm:"not null" json:"name" validate:"required"`
+	Rate      float64   `gorm:"not null" json:"rate" validate:"gt=0"`
+	Inclusive bool      `gorm:"not null;default:false" json:"inclusive"`
+	Category  string    `json:"category,omitempty"`
+	FoodId    *uint32   `json:"foodId,omitempty"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// InvoiceTaxRate is a copy of a tax rate as it was when an invoice was created,
+// so later changes to the rate do not rewrite the invoice.
+type InvoiceTaxRate struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	InvoiceId uint32    `gorm:"not null;index" json:"invoiceId"`
+	TaxRateId uint32    `gorm:"not null" json:"taxRateId"`
+	Name      string    `gorm:"not null" json:"name"`
+	Rate      float64   `gorm:"not null" json:"rate"`
+	Inclusive bool      `gorm:"not null" json:"inclusive"`
+	Category  string    `json:"category,omitempty"`
+	FoodId    *uint32   `json:"foodId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AppliesTo reports whether the tax is charged on the given food from a menu of the given category.
+func (t InvoiceTaxRate) AppliesTo(foodId uint32, category string) bool {
+	if t.FoodId != nil {
+		return *t.FoodId == foodId
+	}
+	if t.Category != "" {
+		return strings.EqualFold(t.Category, category)
+	}
+	return true
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// TokenRevocation signs out of a refresh token family. Access tokens issued from the family are rejected
+// until ExpiresAt, by which time they have all expired on their own and the revocation can be pruned.
+type TokenRevocation struct {
+	ID        uint32    `gorm:"primary_key" json:"id"`
+	FamilyId  string    `gorm:"not null;uniqueIndex" json:"familyId"`
+	UserId    uint32    `gorm:"not null;index" json:"userId"`
+	RevokedBy uint32    `json:"revokedBy"`
+	RevokedAt time.Time `gorm:"not null" json:"revokedAt"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+}
@@ -7,15 +7,17 @@ import (
 
 // User represents the user model.
 type User struct {
-	ID           uint32    `gorm:"primary_key" json:"id"`
-	Name         string    `gorm:"not null" json:"name"`
-	Email        string    `gorm:"not null;unique" json:"email" validate:"email"`
-	Password     string    `gorm:"not null" json:"-"`
-	IsAdmin      bool      `gorm:"default:false" json:"isAdmin"`
-	Token        string    `gorm:"-" json:"token,omitempty"`
-	RefreshToken string    `gorm:"-" json:"refreshToken,omitempty"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID              uint32     `gorm:"primary_key" json:"id"`
+	Name            string     `gorm:"not null" json:"name"`
+	Email           string     `gorm:"not null;unique" json:"email" validate:"email"`
+	Password        string     `gorm:"not null" json:"-"`
+	Role            Role       `gorm:"not null;default:''" json:"role" validate:"oneof=owner manager cashier waiter chef host"`
+	IsAdmin         bool       `gorm:"default:false" json:"isAdmin"` // follows Role, for clients that still read it
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	Token           string     `gorm:"-" json:"token,omitempty"`
+	RefreshToken    string     `gorm:"-" json:"refreshToken,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
 // BeforeCreate is a GORM hook that sets the CreatedAt and UpdatedAt fields.
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserTokenPurpose is what an emailed user token lets its holder do.
+type UserTokenPurpose string
+
+const (
+	VerifyEmail   UserTokenPurpose = "verify_email"
+	ResetPassword UserTokenPurpose = "reset_password"
+)
+
+// UserToken is a token emailed to a user to verify their address or reset their password. The token itself
+// is signed and carries TokenId, the record makes it single use: it is marked used once redeemed, and
+// the unused tokens of the same purpose are dropped whenever a new one is issued.
+type UserToken struct {
+	ID        uint32           `gorm:"primary_key" json:"id"`
+	TokenId   string           `gorm:"not null;uniqueIndex" json:"-"`
+	UserId    uint32           `gorm:"not null;index" json:"userId"`
+	Purpose   UserTokenPurpose `gorm:"not null" json:"purpose"`
+	ExpiresAt time.Time        `gorm:"not null;index" json:"expiresAt"`
+	UsedAt    *time.Time       `json:"usedAt,omitempty"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
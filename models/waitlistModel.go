@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// WaitlistStatus represents where a walk-in party is on the waitlist.
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting WaitlistStatus = "waiting"
+	// WaitlistNotified parties were told their table is ready and are on their way
+	WaitlistNotified WaitlistStatus = "notified"
+	WaitlistSeated   WaitlistStatus = "seated"
+	WaitlistLeft     WaitlistStatus = "left"
+)
+
+// WaitlistEntry is a walk-in party waiting for a table. QuotedWait is the wait in minutes
+// the party was told when it was added, OrderId the order opened when it was seated.
+type WaitlistEntry struct {
+	ID         uint32         `gorm:"primary_key" json:"id"`
+	GuestName  string         `gorm:"not null" json:"guestName" validate:"required"`
+	PartySize  uint           `gorm:"not null" json:"partySize" validate:"gt=0"`
+	Phone      string         `json:"phone"`
+	Notes      string         `json:"notes,omitempty"`
+	Status     WaitlistStatus `gorm:"not null;default:waiting;index" json:"status" validate:"oneof=waiting notified seated left"`
+	QuotedWait uint           `gorm:"not null;default:0" json:"quotedWait"`
+	TableId    *uint          `json:"tableId,omitempty"`
+	OrderId    *uint32        `json:"orderId,omitempty"`
+	SeatedAt   *time.Time     `json:"seatedAt,omitempty"`
+	UserId     uint32         `json:"userId"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+}
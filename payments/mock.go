@@ -0,0 +1,173 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const (
+	// MockProviderName is the name the mock provider is registered under.
+	MockProviderName = "mock"
+	// MockSignatureHeader carries the hex HMAC-SHA256 of a mock webhook body.
+	MockSignatureHeader = "X-Mock-Signature"
+	// MockDeclinedCard is a card token the mock provider always declines.
+	MockDeclinedCard = "tok_declined"
+)
+
+// The mock provider is only registered when a webhook secret is set for it, so a deployment
+// never ends up taking webhooks signed with a secret anyone could know.
+func init() {
+	if secret := os.Getenv("MOCK_PAYMENT_WEBHOOK_SECRET"); secret != "" {
+		Register(NewMockProvider(secret))
+	}
+}
+
+// MockProvider is an in-memory provider for development and tests. It accepts every card except
+// MockDeclinedCard and signs its webhooks with HMAC-SHA256 of the body under the webhook secret.
+// Without a webhook secret it turns every webhook down.
+type MockProvider struct {
+	mu           sync.Mutex
+	secret       []byte
+	sequence     int
+	transactions map[string]*Transaction
+	idempotency  map[string]string
+	refunds      map[string]Transaction
+}
+
+func NewMockProvider(webhookSecret string) *MockProvider {
+	return &MockProvider{
+		secret:       []byte(webhookSecret),
+		transactions: make(map[string]*Transaction),
+		idempotency:  make(map[string]string),
+		refunds:      make(map[string]Transaction),
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return MockProviderName
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, request AuthorizeRequest) (Transaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if request.IdempotencyKey != "" {
+		if id, ok := p.idempotency[request.IdempotencyKey]; ok {
+			return *p.transactions[id], nil
+		}
+	}
+	if request.CardToken == MockDeclinedCard {
+		return Transaction{}, ErrDeclined
+	}
+	if request.Amount <= 0 {
+		return Transaction{}, fmt.Errorf("%w: amount has to be positive", ErrDeclined)
+	}
+	p.sequence++
+	transaction := &Transaction{Id: fmt.Sprintf("mock_%d", p.sequence), Status: Authorized, Amount: request.Amount}
+	p.transactions[transaction.Id] = transaction
+	if request.IdempotencyKey != "" {
+		p.idempotency[request.IdempotencyKey] = transaction.Id
+	}
+	return *transaction, nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, transactionId string) (Transaction, error) {
+	return p.update(transactionId, func(transaction *Transaction) error {
+		switch transaction.Status {
+		case Captured:
+			return nil
+		case Authorized:
+			transaction.Status = Captured
+			return nil
+		}
+		return fmt.Errorf("%w: %s can not be captured", ErrInvalidTransition, transaction.Status)
+	})
+}
+
+func (p *MockProvider) Void(ctx context.Context, transactionId string) (Transaction, error) {
+	return p.update(transactionId, func(transaction *Transaction) error {
+		switch transaction.Status {
+		case Voided:
+			return nil
+		case Authorized:
+			transaction.Status = Voided
+			return nil
+		}
+		return fmt.Errorf("%w: %s can not be voided", ErrInvalidTransition, transaction.Status)
+	})
+}
+
+func (p *MockProvider) Refund(ctx context.Context, transactionId string, amount float64, idempotencyKey string) (Transaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if earlier, ok := p.refunds[idempotencyKey]; ok && idempotencyKey != "" {
+		return earlier, nil
+	}
+	transaction, ok := p.transactions[transactionId]
+	if !ok {
+		return Transaction{}, fmt.Errorf("%w: %s", ErrUnknownTransaction, transactionId)
+	}
+	if transaction.Status != Captured && transaction.Status != Refunded {
+		return Transaction{}, fmt.Errorf("%w: %s can not be refunded", ErrInvalidTransition, transaction.Status)
+	}
+	left := math.Round((transaction.Amount-transaction.Refunded)*100) / 100
+	if amount <= 0 || amount > left {
+		return Transaction{}, fmt.Errorf("%w: %.2f is left to refund", ErrInvalidTransition, left)
+	}
+	transaction.Refunded += amount
+	if transaction.Refunded >= transaction.Amount {
+		transaction.Status = Refunded
+	}
+	if idempotencyKey != "" {
+		p.refunds[idempotencyKey] = *transaction
+	}
+	return *transaction, nil
+}
+
+func (p *MockProvider) update(transactionId string, apply func(*Transaction) error) (Transaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	transaction, ok := p.transactions[transactionId]
+	if !ok {
+		return Transaction{}, fmt.Errorf("%w: %s", ErrUnknownTransaction, transactionId)
+	}
+	if err := apply(transaction); err != nil {
+		return Transaction{}, err
+	}
+	return *transaction, nil
+}
+
+// Sign returns the signature the mock provider puts on a webhook body, for sending test webhooks.
+func (p *MockProvider) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the body's signature and decodes it as a JSON WebhookEvent.
+func (p *MockProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	if len(p.secret) == 0 {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(header.Get(MockSignatureHeader))
+	if err != nil {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+	return event, nil
+}
@@ -0,0 +1,108 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrDeclined is returned when the provider refuses to authorize a payment.
+	ErrDeclined = errors.New("payment declined")
+	// ErrUnknownTransaction is returned for a transaction id the provider doesn't know about.
+	ErrUnknownTransaction = errors.New("unknown transaction")
+	// ErrInvalidTransition is returned when a transaction can't be captured, voided or refunded in its current state.
+	ErrInvalidTransition = errors.New("invalid transaction state")
+	// ErrInvalidSignature is returned when a webhook can't be verified as coming from the provider.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrUnknownProvider is returned when no provider is registered under a name.
+	ErrUnknownProvider = errors.New("unknown payment provider")
+)
+
+// Declined reports whether err is the provider turning a request down for good, as opposed to
+// failing to answer, after which the request may still have gone through on the provider's side.
+func Declined(err error) bool {
+	return errors.Is(err, ErrDeclined) || errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrUnknownTransaction)
+}
+
+// TransactionStatus is where a transaction is in the provider's flow.
+type TransactionStatus string
+
+const (
+	Authorized TransactionStatus = "authorized"
+	Captured   TransactionStatus = "captured"
+	Voided     TransactionStatus = "voided"
+	Refunded   TransactionStatus = "refunded"
+)
+
+// AuthorizeRequest asks a provider to hold Amount on a card. Providers return the transaction
+// created for an IdempotencyKey they have already seen instead of charging the card again.
+type AuthorizeRequest struct {
+	Amount         float64
+	CardToken      string
+	Reference      string
+	IdempotencyKey string
+}
+
+// Transaction is a provider's view of a card payment.
+type Transaction struct {
+	Id       string
+	Status   TransactionStatus
+	Amount   float64
+	Refunded float64
+}
+
+// WebhookEvent is a verified notification from a provider about one of its transactions.
+type WebhookEvent struct {
+	Type          string            `json:"type"`
+	TransactionId string            `json:"transactionId"`
+	Status        TransactionStatus `json:"status"`
+}
+
+// Provider is a card payment gateway. Payments are authorized first and then either captured
+// or voided, captured payments can be refunded in full or in parts. Refunds sent again with an
+// idempotencyKey the provider has already seen return the earlier result instead of refunding twice.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, request AuthorizeRequest) (Transaction, error)
+	Capture(ctx context.Context, transactionId string) (Transaction, error)
+	Void(ctx context.Context, transactionId string) (Transaction, error)
+	Refund(ctx context.Context, transactionId string, amount float64, idempotencyKey string) (Transaction, error)
+	VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]Provider)
+)
+
+// Register makes a provider available under its name, replacing any provider registered with the same name.
+func Register(provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[provider.Name()] = provider
+}
+
+// Get returns the provider registered under name.
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+// Default returns the provider card payments go through, set with PAYMENT_PROVIDER and the mock provider if unset.
+// The mock provider is only registered when MOCK_PAYMENT_WEBHOOK_SECRET is set, card payments fail otherwise.
+func Default() (Provider, error) {
+	name := os.Getenv("PAYMENT_PROVIDER")
+	if name == "" {
+		name = MockProviderName
+	}
+	return Get(name)
+}
@@ -0,0 +1,228 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// LineWidth is how many characters of the default font fit on a line of 80mm paper.
+const LineWidth = 48
+
+const (
+	esc = 0x1b
+	gs  = 0x1d
+)
+
+// Alignment of the text that follows.
+type Alignment byte
+
+const (
+	AlignLeft   Alignment = 0
+	AlignCenter Alignment = 1
+	AlignRight  Alignment = 2
+)
+
+// Builder puts together an ESC/POS byte stream. Text is written as ASCII,
+// anything outside of it is replaced with '?' since printers default to a code page.
+type Builder struct {
+	buf   bytes.Buffer
+	width int
+}
+
+// NewBuilder starts a stream that resets the printer first.
+func NewBuilder() *Builder {
+	b := &Builder{width: LineWidth}
+	b.buf.Write([]byte{esc, '@'})
+	return b
+}
+
+func (b *Builder) Align(alignment Alignment) *Builder {
+	b.buf.Write([]byte{esc, 'a', byte(alignment)})
+	return b
+}
+
+func (b *Builder) Bold(on bool) *Builder {
+	b.buf.Write([]byte{esc, 'E', boolByte(on)})
+	return b
+}
+
+// Size scales the characters that follow, 1 being the normal size and 8 the largest.
+// Lines get proportionally shorter, see Width.
+func (b *Builder) Size(width, height int) *Builder {
+	width, height = clamp(width), clamp(height)
+	b.buf.Write([]byte{gs, '!', byte((width-1)<<4 | (height - 1))})
+	b.width = LineWidth / width
+	return b
+}
+
+// Width is how many characters fit on a line at the current size.
+func (b *Builder) Width() int {
+	return b.width
+}
+
+// Text writes s without ending the line.
+func (b *Builder) Text(s string) *Builder {
+	b.buf.WriteString(ascii(s))
+	return b
+}
+
+// Line writes s followed by a line feed.
+func (b *Builder) Line(s string) *Builder {
+	return b.Text(s).Feed(1)
+}
+
+// Wrap writes s over as many lines as it takes, breaking between words, each line after
+// the first indented by indent spaces.
+func (b *Builder) Wrap(s string, indent int) *Builder {
+	for _, line := range wrap(s, b.width, indent) {
+		b.Line(line)
+	}
+	return b
+}
+
+// Columns writes left and right on one line, right aligned to the end of it.
+// A right column that doesn't fit on the line is cut short.
+func (b *Builder) Columns(left, right string) *Builder {
+	left, right = ascii(left), ascii(right)
+	if len(right) > b.width {
+		right = right[:b.width]
+	}
+	space := b.width - len(right) - 1
+	if len(left) > space {
+		// long names get the line to themselves
+		b.Wrap(left, 2)
+		left = ""
+	}
+	return b.Line(left + strings.Repeat(" ", b.width-len(left)-len(right)) + right)
+}
+
+// Rule draws a line across the paper.
+func (b *Builder) Rule(char string) *Builder {
+	return b.Line(strings.Repeat(char, b.width))
+}
+
+func (b *Builder) Feed(lines int) *Builder {
+	for i := 0; i < lines; i++ {
+		b.buf.WriteByte('\n')
+	}
+	return b
+}
+
+// Cut feeds the paper past the cutter and cuts it.
+func (b *Builder) Cut() *Builder {
+	b.buf.Write([]byte{gs, 'V', 'B', 3})
+	return b
+}
+
+func (b *Builder) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// Text strips the ESC/POS commands out of a stream built with Builder, leaving what gets printed.
+func Text(data []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case esc:
+			switch {
+			case i+1 < len(data) && data[i+1] == '@':
+				i++
+			default:
+				i += 2
+			}
+		case gs:
+			switch {
+			case i+1 < len(data) && data[i+1] == 'V':
+				i += 3
+				out.WriteString("\n-------- cut --------\n")
+			default:
+				i += 2
+			}
+		default:
+			out.WriteByte(data[i])
+		}
+	}
+	return out.String()
+}
+
+func wrap(s string, width, indent int) []string {
+	s = ascii(s)
+	// every line has to take at least one character past the indent
+	if width < 1 {
+		width = 1
+	}
+	if indent >= width {
+		indent = width - 1
+	}
+	if indent < 0 {
+		indent = 0
+	}
+	// the first line keeps the spaces s starts with
+	lead := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		prefix := lead
+		if len(lines) > 0 {
+			prefix = strings.Repeat(" ", indent)
+		}
+		switch {
+		case line == "":
+			line = prefix + word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = strings.Repeat(" ", indent) + word
+		}
+		for len(line) > width {
+			lines = append(lines, line[:width])
+			line = strings.Repeat(" ", indent) + line[width:]
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func ascii(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	out := make([]byte, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return string(out)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func boolByte(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+func clamp(size int) int {
+	if size < 1 {
+		return 1
+	}
+	if size > 8 {
+		return 8
+	}
+	return size
+}
@@ -0,0 +1,28 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColumnsFitsTheLine(t *testing.T) {
+	b := NewBuilder().Size(2, 2)
+	b.Columns("12:30", strings.Repeat("grill", 10))
+	for _, line := range strings.Split(strings.TrimSuffix(Text(b.Bytes()), "\n"), "\n") {
+		if len(line) > b.Width() {
+			t.Errorf("line %q is longer than %d", line, b.Width())
+		}
+	}
+}
+
+func TestWrapIndentWiderThanTheLine(t *testing.T) {
+	lines := wrap("a long word that has to wrap", 4, 6)
+	if len(lines) == 0 {
+		t.Fatal("nothing was written")
+	}
+	for _, line := range lines {
+		if len(line) > 4 {
+			t.Errorf("line %q is longer than 4", line)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package printer
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// FakePrinter accepts raw print jobs like a network printer would and keeps them,
+// for trying out printing without the hardware.
+type FakePrinter struct {
+	listener net.Listener
+	mu       sync.Mutex
+	jobs     [][]byte
+	received chan []byte
+}
+
+// ListenFake starts a fake printer on address, ":9100" or "127.0.0.1:0" for any free port.
+func ListenFake(address string) (*FakePrinter, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	printer := &FakePrinter{listener: listener, received: make(chan []byte, 16)}
+	go printer.serve()
+	return printer, nil
+}
+
+// Addr is the address the fake printer listens on.
+func (p *FakePrinter) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Jobs returns every job received so far.
+func (p *FakePrinter) Jobs() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]byte(nil), p.jobs...)
+}
+
+// Received delivers jobs as they come in. Jobs are dropped from it, but not from Jobs, when nobody reads it.
+func (p *FakePrinter) Received() <-chan []byte {
+	return p.received
+}
+
+func (p *FakePrinter) Close() error {
+	return p.listener.Close()
+}
+
+func (p *FakePrinter) serve() {
+	defer close(p.received)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			// a job is everything sent over one connection
+			job, err := io.ReadAll(conn)
+			if err != nil || len(job) == 0 {
+				return
+			}
+			p.mu.Lock()
+			p.jobs = append(p.jobs, job)
+			p.mu.Unlock()
+			select {
+			case p.received <- job:
+			default:
+			}
+		}()
+	}
+}
@@ -0,0 +1,95 @@
+package printer
+
+import (
+	"fmt"
+	"github.com/KhetwalDevesh/restaurant-management/receipts"
+	"strings"
+)
+
+// Receipt lays the receipt out for an 80mm receipt printer.
+func Receipt(receipt receipts.Receipt) []byte {
+	b := NewBuilder()
+
+	b.Align(AlignCenter).Bold(true).Size(2, 2).Wrap(receipt.Restaurant.Name, 0)
+	b.Size(1, 1).Bold(false)
+	for _, detail := range []string{receipt.Restaurant.Address, receipt.Restaurant.Phone} {
+		if detail != "" {
+			b.Wrap(detail, 0)
+		}
+	}
+	if receipt.Restaurant.TaxId != "" {
+		b.Line("Tax ID: " + receipt.Restaurant.TaxId)
+	}
+	b.Feed(1).Align(AlignLeft)
+
+	title := fmt.Sprintf("Invoice #%d", receipt.InvoiceId)
+	if receipt.Part != "" {
+		title += " (" + receipt.Part + ")"
+	}
+	b.Bold(true).Line(title).Bold(false)
+	table := ""
+	if receipt.TableNumber != 0 {
+		table = fmt.Sprintf("Table %d", receipt.TableNumber)
+	}
+	b.Columns(fmt.Sprintf("Order #%d", receipt.OrderId), table)
+	if receipt.OrderType != "" {
+		b.Line(strings.TrimSpace(receipt.OrderType + " " + receipt.Customer))
+	}
+	if receipt.Address != "" {
+		b.Wrap(receipt.Address, 0)
+	}
+	b.Line(receipt.IssuedAt.Format("02 Jan 2006 15:04"))
+	b.Rule("-")
+
+	for _, line := range receipt.Lines {
+		b.Columns(fmt.Sprintf("%s x %s", line.Quantity, line.Name), money(line.Amount))
+		if len(line.Details) > 0 {
+			b.Wrap("  "+strings.Join(line.Details, ", "), 2)
+		}
+	}
+	b.Rule("-")
+
+	b.Columns("Subtotal", money(receipt.Subtotal))
+	for _, discount := range receipt.Discounts {
+		b.Columns(discount.Name, money(-discount.Amount))
+	}
+	for _, tax := range receipt.Taxes {
+		if tax.Included {
+			b.Columns(tax.Name+" (included)", money(tax.Amount))
+		} else {
+			b.Columns(tax.Name, money(tax.Amount))
+		}
+	}
+	for _, charge := range receipt.Charges {
+		b.Columns(charge.Name, money(charge.Amount))
+	}
+	b.Bold(true).Size(1, 2).Columns("TOTAL", money(receipt.Total)).Size(1, 1).Bold(false)
+
+	if len(receipt.Payments) > 0 {
+		b.Rule("-")
+		for _, payment := range receipt.Payments {
+			label := strings.ToUpper(payment.Method)
+			if payment.Note != "" {
+				label += " " + payment.Note
+			}
+			b.Columns(label, money(payment.Amount))
+			if payment.Tip != 0 {
+				b.Columns("  Tip", money(payment.Tip))
+			}
+		}
+		b.Columns("Paid", money(receipt.AmountPaid))
+		if receipt.Tips != 0 {
+			b.Columns("Tips", money(receipt.Tips))
+		}
+		b.Bold(true).Columns("Balance due", money(receipt.Balance)).Bold(false)
+	}
+
+	b.Feed(1).Align(AlignCenter)
+	b.Line(strings.ToUpper(strings.ReplaceAll(receipt.PaymentStatus, "_", " ")))
+	b.Wrap(receipt.Restaurant.Footer, 0)
+	return b.Feed(3).Cut().Bytes()
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
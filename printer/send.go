@@ -0,0 +1,39 @@
+package printer
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DefaultPort is the raw printing port network printers listen on.
+const DefaultPort = "9100"
+
+// sendTimeout bounds how long a print job may take when the context has no deadline.
+const sendTimeout = 10 * time.Second
+
+// Send writes the job to the printer at address, "host" or "host:port", over raw TCP.
+func Send(ctx context.Context, address string, job []byte) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, DefaultPort)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+	if _, err := conn.Write(job); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendTicket(t *testing.T) {
+	fake, err := ListenFake("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+
+	job := Ticket(KitchenTicket{
+		OrderId:     42,
+		TableNumber: 7,
+		Station:     "grill",
+		PrintedAt:   time.Date(2024, 1, 2, 12, 30, 0, 0, time.UTC),
+		Items:       []TicketItem{{Quantity: 2, Name: "Burger", Notes: "no onions"}},
+	})
+	if err := Send(context.Background(), fake.Addr(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []byte
+	select {
+	case received = <-fake.Received():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the printer got nothing")
+	}
+	if !bytes.Equal(received, job) {
+		t.Fatalf("the printer got %q, want %q", received, job)
+	}
+	for _, want := range [][]byte{
+		{esc, '@'},            // reset
+		{gs, '!', 0x11},       // double size header
+		[]byte("TABLE 7\n"),   // table
+		[]byte("ORDER #42\n"), // order
+		[]byte("12:30"),       // printed at
+		[]byte("grill\n"),     // station
+		[]byte("2x Burger\n"), // item
+		[]byte("** no onions"),
+	} {
+		if !bytes.Contains(received, want) {
+			t.Errorf("ticket is missing %q", want)
+		}
+	}
+	if !bytes.HasSuffix(received, []byte{gs, 'V', 'B', 3}) {
+		t.Error("ticket does not end with a cut")
+	}
+}
@@ -0,0 +1,72 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KitchenTicket is a set of order items sent to the kitchen together.
+// Takeaway and delivery tickets have no table, OrderType, Customer and PickupAt tell the pass who they are for.
+type KitchenTicket struct {
+	OrderId     uint32
+	TableNumber uint
+	OrderType   string
+	Customer    string
+	PickupAt    *time.Time
+	Station     string
+	Course      uint32
+	PrintedAt   time.Time
+	Items       []TicketItem
+}
+
+// TicketItem is one order item on a kitchen ticket, Details being its variant and modifiers.
+type TicketItem struct {
+	Quantity uint32
+	Name     string
+	Seat     uint32
+	Details  []string
+	Notes    string
+}
+
+// Ticket lays the kitchen ticket out in a large font so it can be read from across the pass.
+func Ticket(ticket KitchenTicket) []byte {
+	b := NewBuilder()
+
+	b.Align(AlignCenter).Bold(true).Size(2, 2)
+	if ticket.TableNumber != 0 {
+		b.Line(fmt.Sprintf("TABLE %d", ticket.TableNumber))
+	}
+	if ticket.OrderType != "" {
+		b.Line(strings.ToUpper(ticket.OrderType))
+	}
+	b.Line(fmt.Sprintf("ORDER #%d", ticket.OrderId))
+	if ticket.PickupAt != nil {
+		b.Line("PICKUP " + ticket.PickupAt.Format("15:04"))
+	}
+	if ticket.Course != 0 {
+		b.Line(fmt.Sprintf("COURSE %d", ticket.Course))
+	}
+	b.Size(1, 1).Bold(false).Align(AlignLeft)
+	b.Columns(ticket.PrintedAt.Format("15:04"), ticket.Station)
+	if ticket.Customer != "" {
+		b.Line(ticket.Customer)
+	}
+	b.Rule("=")
+
+	for _, item := range ticket.Items {
+		b.Bold(true).Size(2, 2).Wrap(fmt.Sprintf("%dx %s", item.Quantity, item.Name), 3)
+		b.Size(1, 2).Bold(false)
+		if item.Seat != 0 {
+			b.Line(fmt.Sprintf("   seat %d", item.Seat))
+		}
+		for _, detail := range item.Details {
+			b.Wrap("   + "+detail, 5)
+		}
+		if item.Notes != "" {
+			b.Bold(true).Wrap("   ** "+item.Notes, 6).Bold(false)
+		}
+		b.Size(1, 1).Rule("-")
+	}
+	return b.Feed(3).Cut().Bytes()
+}
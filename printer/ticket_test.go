@@ -0,0 +1,19 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTicketWithLongStationName(t *testing.T) {
+	job := Ticket(KitchenTicket{
+		OrderId:   1,
+		Station:   strings.Repeat("pastry and desserts ", 5),
+		PrintedAt: time.Date(2024, 1, 2, 12, 30, 0, 0, time.UTC),
+		Items:     []TicketItem{{Quantity: 1, Name: "Tart"}},
+	})
+	if !strings.Contains(Text(job), "12:30") {
+		t.Errorf("ticket lost the time it was printed:\n%s", Text(job))
+	}
+}
@@ -0,0 +1,160 @@
+package receipts
+
+import (
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"io"
+	"strings"
+)
+
+const (
+	pdfMargin     = 15.0
+	pdfLineHeight = 6.0
+	pdfPageWidth  = 210.0
+	// widths of the item, quantity, unit price and amount columns
+	pdfItemWidth     = 100.0
+	pdfQuantityWidth = 20.0
+	pdfPriceWidth    = 30.0
+	pdfAmountWidth   = pdfPageWidth - 2*pdfMargin - pdfItemWidth - pdfQuantityWidth - pdfPriceWidth
+)
+
+// WritePDF renders the receipt as an A4 PDF.
+func WritePDF(w io.Writer, receipt Receipt) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetTitle(fmt.Sprintf("Invoice %d", receipt.InvoiceId), true)
+	pdf.SetCreator(receipt.Restaurant.Name, true)
+	pdf.AliasNbPages("")
+	// the core fonts are cp1252, this maps the UTF-8 text onto it
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, pdfLineHeight, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// restaurant header
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr(receipt.Restaurant.Name), "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, detail := range []string{receipt.Restaurant.Address, receipt.Restaurant.Phone} {
+		if detail != "" {
+			pdf.CellFormat(0, 5, tr(detail), "", 1, "C", false, 0, "")
+		}
+	}
+	if receipt.Restaurant.TaxId != "" {
+		pdf.CellFormat(0, 5, tr("Tax ID: "+receipt.Restaurant.TaxId), "", 1, "C", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// invoice details
+	pdf.SetFont("Helvetica", "B", 12)
+	title := fmt.Sprintf("Invoice #%d", receipt.InvoiceId)
+	if receipt.Part != "" {
+		title += " (" + receipt.Part + ")"
+	}
+	pdf.CellFormat(0, 8, tr(title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, pdfLineHeight, fmt.Sprintf("Order #%d", receipt.OrderId), "", 1, "L", false, 0, "")
+	if receipt.TableNumber != 0 {
+		pdf.CellFormat(0, pdfLineHeight, fmt.Sprintf("Table %d", receipt.TableNumber), "", 1, "L", false, 0, "")
+	}
+	if receipt.OrderType != "" {
+		pdf.CellFormat(0, pdfLineHeight, tr(strings.TrimSpace(receipt.OrderType+" "+receipt.Customer)), "", 1, "L", false, 0, "")
+	}
+	if receipt.Address != "" {
+		pdf.MultiCell(0, pdfLineHeight, tr(receipt.Address), "", "L", false)
+	}
+	pdf.CellFormat(0, pdfLineHeight, receipt.IssuedAt.Format("02 Jan 2006 15:04"), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	// items
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	pdf.CellFormat(pdfItemWidth, pdfLineHeight+1, "Item", "B", 0, "L", true, 0, "")
+	pdf.CellFormat(pdfQuantityWidth, pdfLineHeight+1, "Qty", "B", 0, "R", true, 0, "")
+	pdf.CellFormat(pdfPriceWidth, pdfLineHeight+1, "Price", "B", 0, "R", true, 0, "")
+	pdf.CellFormat(pdfAmountWidth, pdfLineHeight+1, "Amount", "B", 1, "R", true, 0, "")
+	for _, line := range receipt.Lines {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(pdfItemWidth, pdfLineHeight, tr(line.Name), "", 0, "L", false, 0, "")
+		pdf.CellFormat(pdfQuantityWidth, pdfLineHeight, tr(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(pdfPriceWidth, pdfLineHeight, money(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(pdfAmountWidth, pdfLineHeight, money(line.Amount), "", 1, "R", false, 0, "")
+		if len(line.Details) > 0 {
+			pdf.SetFont("Helvetica", "I", 8)
+			pdf.SetX(pdfMargin + 4)
+			pdf.MultiCell(pdfItemWidth-4, 4, tr(strings.Join(line.Details, ", ")), "", "L", false)
+		}
+	}
+	pdf.Ln(2)
+	pdf.Line(pdfMargin, pdf.GetY(), pdfPageWidth-pdfMargin, pdf.GetY())
+	pdf.Ln(2)
+
+	// totals
+	total := func(label, amount string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(pdfItemWidth+pdfQuantityWidth+pdfPriceWidth, pdfLineHeight, tr(label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(pdfAmountWidth, pdfLineHeight, amount, "", 1, "R", false, 0, "")
+	}
+	total("Subtotal", money(receipt.Subtotal), false)
+	for _, discount := range receipt.Discounts {
+		total(discount.Name, money(-discount.Amount), false)
+	}
+	for _, tax := range receipt.Taxes {
+		if tax.Included {
+			total(tax.Name+" (included)", money(tax.Amount), false)
+		} else {
+			total(tax.Name, money(tax.Amount), false)
+		}
+	}
+	for _, charge := range receipt.Charges {
+		total(charge.Name, money(charge.Amount), false)
+	}
+	total("Total", money(receipt.Total), true)
+
+	// payments
+	if len(receipt.Payments) > 0 {
+		pdf.Ln(2)
+		for _, payment := range receipt.Payments {
+			label := capitalize(payment.Method)
+			if payment.Note != "" {
+				label += " - " + payment.Note
+			}
+			total(label, money(payment.Amount), false)
+			if payment.Tip != 0 {
+				total("Tip", money(payment.Tip), false)
+			}
+		}
+		total("Paid", money(receipt.AmountPaid), false)
+		if receipt.Tips != 0 {
+			total("Tips", money(receipt.Tips), false)
+		}
+		total("Balance due", money(receipt.Balance), true)
+	}
+	pdf.Ln(2)
+	total("Status", strings.ReplaceAll(receipt.PaymentStatus, "_", " "), false)
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "I", 10)
+	pdf.MultiCell(0, pdfLineHeight, tr(receipt.Restaurant.Footer), "", "C", false)
+
+	return pdf.Output(w)
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,82 @@
+package receipts
+
+import (
+	"os"
+	"time"
+)
+
+// Restaurant is who the receipt is from.
+type Restaurant struct {
+	Name    string
+	Address string
+	Phone   string
+	TaxId   string
+	Footer  string
+}
+
+// RestaurantFromEnv reads the restaurant details printed on receipts from RESTAURANT_NAME,
+// RESTAURANT_ADDRESS, RESTAURANT_PHONE, RESTAURANT_TAX_ID and RESTAURANT_RECEIPT_FOOTER.
+func RestaurantFromEnv() Restaurant {
+	restaurant := Restaurant{
+		Name:    os.Getenv("RESTAURANT_NAME"),
+		Address: os.Getenv("RESTAURANT_ADDRESS"),
+		Phone:   os.Getenv("RESTAURANT_PHONE"),
+		TaxId:   os.Getenv("RESTAURANT_TAX_ID"),
+		Footer:  os.Getenv("RESTAURANT_RECEIPT_FOOTER"),
+	}
+	if restaurant.Name == "" {
+		restaurant.Name = "Restaurant"
+	}
+	if restaurant.Footer == "" {
+		restaurant.Footer = "Thank you for dining with us!"
+	}
+	return restaurant
+}
+
+// Receipt is everything printed on a receipt, independent of how it is rendered.
+type Receipt struct {
+	Restaurant    Restaurant
+	InvoiceId     uint32
+	OrderId       uint32
+	TableNumber   uint
+	OrderType     string // "Takeaway" or "Delivery", empty for dine-in
+	Customer      string
+	Address       string
+	Part          string
+	IssuedAt      time.Time
+	Lines         []Line
+	Subtotal      float64
+	Discounts     []Adjustment
+	Taxes         []Adjustment
+	Charges       []Adjustment // added on top of taxes, like the delivery fee
+	Total         float64
+	Payments      []Payment
+	AmountPaid    float64
+	Tips          float64
+	Balance       float64
+	PaymentStatus string
+}
+
+// Line is one item on the receipt, Details being its variant, modifiers and notes.
+type Line struct {
+	Name      string
+	Details   []string
+	Quantity  string
+	UnitPrice float64
+	Amount    float64
+}
+
+// Adjustment is a discount or a tax. Included taxes are part of the line amounts already.
+type Adjustment struct {
+	Name     string
+	Amount   float64
+	Included bool
+}
+
+// Payment is one tender or refund, refunds having a negative Amount.
+type Payment struct {
+	Method string
+	Amount float64
+	Tip    float64
+	Note   string
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func FloorRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/floor", controller.GetFloor())
+	incomingRoutes.GET("/floorSections", controller.GetFloorSections())
+	incomingRoutes.POST("/floorSections", middleware.Authorize(models.PermissionManageFloor), controller.CreateFloorSection())
+	incomingRoutes.PATCH("/floorSections/:section_id", middleware.Authorize(models.PermissionManageFloor), controller.UpdateFloorSection())
+}
@@ -2,12 +2,17 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func FoodRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/foods", controller.GetFoods())
 	incomingRoutes.GET("/foods/:food_id", controller.GetFood())
-	incomingRoutes.POST("/foods", controller.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controller.UpdateFood())
+	incomingRoutes.POST("/foods", middleware.Authorize(models.PermissionManageMenu), controller.CreateFood())
+	incomingRoutes.PATCH("/foods/:food_id", middleware.Authorize(models.PermissionManageMenu), controller.UpdateFood())
+	incomingRoutes.GET("/foods/:food_id/variants", controller.GetFoodVariants())
+	incomingRoutes.POST("/foods/:food_id/variants", middleware.Authorize(models.PermissionManageMenu), controller.CreateFoodVariant())
+	incomingRoutes.PATCH("/foods/:food_id/variants/:variant_id", middleware.Authorize(models.PermissionManageMenu), controller.UpdateFoodVariant())
 }
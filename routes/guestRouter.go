@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// GuestRoutes are used by guests ordering from their phone, authenticated by their table's QR code instead of a staff token.
+func GuestRoutes(incomingRoutes *gin.Engine) {
+	guestRoutes := incomingRoutes.Group("/guest", middleware.GuestAuthentication())
+	guestRoutes.GET("/menu", controller.GetGuestMenu())
+	guestRoutes.GET("/orders", controller.GetGuestOrders())
+	guestRoutes.POST("/orders", controller.SubmitGuestOrder())
+}
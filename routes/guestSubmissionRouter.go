@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func GuestSubmissionRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/guestSubmissions", controller.GetGuestSubmissions())
+	incomingRoutes.POST("/guestSubmissions/:submission_id/approve", middleware.Authorize(models.PermissionTakeOrders), controller.ApproveGuestSubmission())
+	incomingRoutes.POST("/guestSubmissions/:submission_id/reject", middleware.Authorize(models.PermissionTakeOrders), controller.RejectGuestSubmission())
+}
@@ -2,12 +2,17 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func InvoiceRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/invoices", controller.GetInvoices())
 	incomingRoutes.GET("/invoices/:invoice_id", controller.GetInvoice())
-	incomingRoutes.POST("/invoices", controller.CreateInvoice())
-	incomingRoutes.PATCH("/invoices/:invoice_id", controller.UpdateInvoice())
+	incomingRoutes.GET("/invoices/:invoice_id/pdf", controller.GetInvoicePDF())
+	incomingRoutes.POST("/invoices", middleware.Authorize(models.PermissionBill), controller.CreateInvoice())
+	incomingRoutes.PATCH("/invoices/:invoice_id", middleware.Authorize(models.PermissionBill), controller.UpdateInvoice())
+	incomingRoutes.POST("/invoices/:invoice_id/discounts", middleware.Authorize(models.PermissionBill), controller.ApplyInvoiceDiscount())
+	incomingRoutes.DELETE("/invoices/:invoice_id/discounts/:discount_id", middleware.Authorize(models.PermissionBill), controller.RemoveInvoiceDiscount())
 }
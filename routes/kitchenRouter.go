@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kitchen/queue", controller.GetKitchenQueue())
+	incomingRoutes.GET("/kitchen/stream", controller.StreamKitchen())
+	incomingRoutes.POST("/kitchen/items/:order_item_id/bump", middleware.Authorize(models.PermissionWorkKitchen), controller.BumpOrderItem())
+	incomingRoutes.POST("/kitchen/items/:order_item_id/recall", middleware.Authorize(models.PermissionWorkKitchen), controller.RecallOrderItem())
+}
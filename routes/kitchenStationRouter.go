@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenStationRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kitchenStations", controller.GetKitchenStations())
+	incomingRoutes.POST("/kitchenStations", middleware.Authorize(models.PermissionManageSetup), controller.CreateKitchenStation())
+	incomingRoutes.PATCH("/kitchenStations/:station_id", middleware.Authorize(models.PermissionManageSetup), controller.UpdateKitchenStation())
+	incomingRoutes.POST("/kitchenStations/:station_id/routes", middleware.Authorize(models.PermissionManageSetup), controller.CreateStationRoute())
+	incomingRoutes.DELETE("/stationRoutes/:route_id", middleware.Authorize(models.PermissionManageSetup), controller.DeleteStationRoute())
+}
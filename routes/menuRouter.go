@@ -2,12 +2,14 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func MenuRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/menus", controller.GetMenus())
 	incomingRoutes.GET("/menus/:menu_id", controller.GetMenu())
-	incomingRoutes.POST("/menus", controller.CreateMenu())
-	incomingRoutes.PATCH("/menus/:menu_id", controller.UpdateMenu())
+	incomingRoutes.POST("/menus", middleware.Authorize(models.PermissionManageMenu), controller.CreateMenu())
+	incomingRoutes.PATCH("/menus/:menu_id", middleware.Authorize(models.PermissionManageMenu), controller.UpdateMenu())
 }
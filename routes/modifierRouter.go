@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func ModifierRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/foods/:food_id/modifierGroups", controller.GetModifierGroups())
+	incomingRoutes.POST("/foods/:food_id/modifierGroups", middleware.Authorize(models.PermissionManageMenu), controller.CreateModifierGroup())
+	incomingRoutes.PATCH("/modifierGroups/:modifier_group_id", middleware.Authorize(models.PermissionManageMenu), controller.UpdateModifierGroup())
+	incomingRoutes.POST("/modifierGroups/:modifier_group_id/modifiers", middleware.Authorize(models.PermissionManageMenu), controller.CreateModifier())
+	incomingRoutes.PATCH("/modifiers/:modifier_id", middleware.Authorize(models.PermissionManageMenu), controller.UpdateModifier())
+}
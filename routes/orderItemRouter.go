@@ -2,13 +2,15 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func OrderItemRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orderItems", controller.GetOrderItems())
-	incomingRoutes.GET("/orderItems/:orderItem_id", controller.GetOrderItem())
+	incomingRoutes.GET("/orderItems/:order_item_id", controller.GetOrderItem())
 	incomingRoutes.GET("/orderItems-order/:order_id", controller.GetOrderItemsByOrder())
-	incomingRoutes.POST("/orderItems", controller.CreateOrderItem())
-	incomingRoutes.PATCH("/orderItems/:orderItem_id", controller.UpdateOrderItem())
+	incomingRoutes.POST("/orderItems", middleware.Authorize(models.PermissionTakeOrders), controller.CreateOrderItem())
+	incomingRoutes.PATCH("/orderItems/:order_item_id", middleware.Authorize(models.PermissionTakeOrders), controller.UpdateOrderItem())
 }
@@ -2,12 +2,24 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func OrderRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orders", controller.GetOrders())
 	incomingRoutes.GET("/orders/:order_id", controller.GetOrder())
-	incomingRoutes.POST("/orders", controller.CreateOrder())
-	incomingRoutes.PATCH("/orders/:order_id", controller.UpdateOrder())
+	incomingRoutes.GET("/orders/:order_id/courses", controller.GetOrderCourses())
+	incomingRoutes.GET("/orders/:order_id/history", controller.GetOrderHistory())
+	incomingRoutes.GET("/orders/:order_id/invoices", controller.GetOrderInvoices())
+	incomingRoutes.POST("/orders", middleware.Authorize(models.PermissionTakeOrders), controller.CreateOrder())
+	incomingRoutes.POST("/orders/:order_id/courses/:course/fire", middleware.Authorize(models.PermissionTakeOrders), controller.FireCourse())
+	incomingRoutes.POST("/orders/:order_id/driver", middleware.Authorize(models.PermissionTakeOrders), controller.AssignDriver())
+	incomingRoutes.POST("/orders/:order_id/merge", middleware.Authorize(models.PermissionTakeOrders), controller.MergeOrders())
+	incomingRoutes.POST("/orders/:order_id/split", middleware.Authorize(models.PermissionBill), controller.SplitOrderBill())
+	incomingRoutes.POST("/orders/:order_id/splitItems", middleware.Authorize(models.PermissionTakeOrders), controller.SplitOrder())
+	incomingRoutes.POST("/orders/:order_id/transfer", middleware.Authorize(models.PermissionTakeOrders), controller.TransferOrder())
+	incomingRoutes.POST("/orders/:order_id/transition", middleware.Authorize(models.PermissionTakeOrders), controller.TransitionOrder())
+	incomingRoutes.PATCH("/orders/:order_id", middleware.Authorize(models.PermissionTakeOrders), controller.UpdateOrder())
 }
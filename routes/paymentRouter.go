@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func PaymentRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/invoices/:invoice_id/payments", controller.GetInvoicePayments())
+	incomingRoutes.POST("/invoices/:invoice_id/payments", middleware.Authorize(models.PermissionBill), controller.RecordPayment())
+	incomingRoutes.POST("/payments/:payment_id/refunds", middleware.Authorize(models.PermissionRefund), controller.RefundPayment())
+}
+
+// PaymentWebhookRoutes are called by payment providers, which authenticate with webhook signatures instead of user tokens.
+func PaymentWebhookRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/payments/webhooks/:provider", controller.PaymentWebhook())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func PricingRuleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/pricingRules", controller.GetPricingRules())
+	incomingRoutes.POST("/pricingRules", middleware.Authorize(models.PermissionManagePricing), controller.CreatePricingRule())
+	incomingRoutes.PATCH("/pricingRules/:pricing_rule_id", middleware.Authorize(models.PermissionManagePricing), controller.UpdatePricingRule())
+}
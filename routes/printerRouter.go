@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func PrinterRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/printers", controller.GetPrinters())
+	incomingRoutes.POST("/printers", middleware.Authorize(models.PermissionManageSetup), controller.CreatePrinter())
+	incomingRoutes.PATCH("/printers/:printer_id", middleware.Authorize(models.PermissionManageSetup), controller.UpdatePrinter())
+	incomingRoutes.GET("/invoices/:invoice_id/escpos", controller.GetInvoiceEscPos())
+	incomingRoutes.POST("/invoices/:invoice_id/print", middleware.Authorize(models.PermissionBill), controller.PrintInvoice())
+	incomingRoutes.POST("/orders/:order_id/ticket", middleware.Authorize(models.PermissionTakeOrders), controller.PrintKitchenTicket())
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func PromotionRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/promotions", controller.GetPromotions())
+	incomingRoutes.GET("/promotions/:promotion_id/redemptions", controller.GetPromotionRedemptions())
+	incomingRoutes.POST("/promotions", middleware.Authorize(models.PermissionManagePricing), controller.CreatePromotion())
+	incomingRoutes.PATCH("/promotions/:promotion_id", middleware.Authorize(models.PermissionManagePricing), controller.UpdatePromotion())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func ReservationRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/reservations", controller.GetReservations())
+	incomingRoutes.GET("/reservations/availability", controller.GetAvailability())
+	incomingRoutes.GET("/reservations/:reservation_id", controller.GetReservation())
+	incomingRoutes.POST("/reservations", middleware.Authorize(models.PermissionSeatGuests), controller.CreateReservation())
+	incomingRoutes.POST("/reservations/:reservation_id/cancel", middleware.Authorize(models.PermissionSeatGuests), controller.CancelReservation())
+	incomingRoutes.PATCH("/reservations/:reservation_id", middleware.Authorize(models.PermissionSeatGuests), controller.UpdateReservation())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func SessionRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/sessions", controller.GetSessions())
+	incomingRoutes.GET("/sessions/staff", middleware.Authorize(models.PermissionViewSessions), controller.GetStaffSessions())
+	incomingRoutes.DELETE("/sessions/:session_id", controller.EndSession())
+}
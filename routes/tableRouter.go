@@ -2,12 +2,18 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
 func TableRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/tables", controller.GetTables())
 	incomingRoutes.GET("/tables/:table_id", controller.GetTable())
-	incomingRoutes.POST("/tables", controller.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controller.UpdateTable())
+	incomingRoutes.GET("/tables/:table_id/guestToken", middleware.Authorize(models.PermissionManageFloor), controller.GetTableGuestToken())
+	incomingRoutes.GET("/tables/:table_id/qr", middleware.Authorize(models.PermissionManageFloor), controller.GetTableQRCode())
+	incomingRoutes.POST("/tables", middleware.Authorize(models.PermissionManageFloor), controller.CreateTable())
+	incomingRoutes.PATCH("/tables/:table_id", middleware.Authorize(models.PermissionManageFloor), controller.UpdateTable())
+	incomingRoutes.POST("/tables/:table_id/guestToken/rotate", middleware.Authorize(models.PermissionManageFloor), controller.RotateTableGuestToken())
+	incomingRoutes.POST("/tables/:table_id/status", middleware.Authorize(models.PermissionSeatGuests), controller.SetTableStatus())
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRateRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/taxRates", controller.GetTaxRates())
+	incomingRoutes.POST("/taxRates", middleware.Authorize(models.PermissionManagePricing), controller.CreateTaxRate())
+	incomingRoutes.PATCH("/taxRates/:tax_rate_id", middleware.Authorize(models.PermissionManagePricing), controller.UpdateTaxRate())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// TokenRoutes are registered before the authentication middleware, the access token being refreshed has usually expired.
+func TokenRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/token/refresh", controller.RefreshToken())
+	incomingRoutes.POST("/logout", middleware.Authentication(), controller.Logout())
+	incomingRoutes.POST("/logout/all", middleware.Authentication(), controller.LogoutAll())
+}
@@ -2,12 +2,24 @@ package routes
 
 import (
 	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
 	"github.com/gin-gonic/gin"
 )
 
+// UserRoutes are registered before the authentication middleware so that signing up, logging in and
+// recovering an account need no token, the routes managing users authenticate on their own.
 func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controller.GetUser())
+	userRoutes := incomingRoutes.Group("/users", middleware.Authentication(), middleware.Authorize(models.PermissionManageUsers))
+	userRoutes.GET("", controller.GetUsers())
+	userRoutes.GET("/:user_id", controller.GetUser())
+	userRoutes.PATCH("/:user_id/role", controller.SetUserRole())
+	userRoutes.POST("/:user_id/logout", controller.LogoutUser())
+	incomingRoutes.GET("/roles", middleware.Authentication(), controller.GetRoles())
 	incomingRoutes.POST("/signup", controller.SignUp())
 	incomingRoutes.POST("/login", controller.Login())
+	incomingRoutes.GET("/verifyEmail", controller.VerifyEmail())
+	incomingRoutes.POST("/verifyEmail/resend", controller.ResendVerificationEmail())
+	incomingRoutes.POST("/password/forgot", controller.ForgotPassword())
+	incomingRoutes.POST("/password/reset", controller.ResetPassword())
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "github.com/KhetwalDevesh/restaurant-management/controllers"
+	"github.com/KhetwalDevesh/restaurant-management/middleware"
+	"github.com/KhetwalDevesh/restaurant-management/models"
+	"github.com/gin-gonic/gin"
+)
+
+func WaitlistRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/waitlist", controller.GetWaitlist())
+	incomingRoutes.GET("/waitlist/estimate", controller.GetWaitEstimate())
+	incomingRoutes.POST("/waitlist", middleware.Authorize(models.PermissionSeatGuests), controller.AddToWaitlist())
+	incomingRoutes.POST("/waitlist/:entry_id/seat", middleware.Authorize(models.PermissionSeatGuests), controller.SeatWaitlistEntry())
+	incomingRoutes.PATCH("/waitlist/:entry_id", middleware.Authorize(models.PermissionSeatGuests), controller.UpdateWaitlistEntry())
+}